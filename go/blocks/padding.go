@@ -25,7 +25,13 @@ func Pkcs7(b []byte, blocksize int) ([]byte, error) {
 // StripIfValidPkcs7 returns a stripped version of a slice padded with PKCS7
 // padding if the padding is valid. Else returns an error.
 func StripIfValidPkcs7(b []byte) ([]byte, error) {
-	valid, n, err := ValidPkcs7(b)
+	return StripIfValidPkcs7Blocksize(b, aes.BlockSize)
+}
+
+// StripIfValidPkcs7Blocksize is like StripIfValidPkcs7 for a slice padded to
+// the specified blocksize
+func StripIfValidPkcs7Blocksize(b []byte, blocksize int) ([]byte, error) {
+	valid, n, err := ValidPkcs7Blocksize(b, blocksize)
 	if !valid {
 		return b, err
 	}
@@ -37,13 +43,23 @@ func StripIfValidPkcs7(b []byte) ([]byte, error) {
 
 // ValidPkcs7 determines if a slice has proper PKCS7 padding and returns the number of padding bytes.
 func ValidPkcs7(b []byte) (bool, int, error) {
+	return ValidPkcs7Blocksize(b, aes.BlockSize)
+}
+
+// ValidPkcs7Blocksize is like ValidPkcs7 for a slice padded to the specified
+// blocksize
+func ValidPkcs7Blocksize(b []byte, blocksize int) (bool, int, error) {
+	if blocksize < 1 || blocksize > 255 {
+		return false, 0, fmt.Errorf("invalid blocksize %d", blocksize)
+	}
+
 	length := len(b)
 	if length < 1 {
 		return false, 0, errors.New("empty slice")
 	}
 
-	if length%aes.BlockSize != 0 {
-		return false, 0, fmt.Errorf("invalid padding: len(%s) is not a multiple of %d", string(b), aes.BlockSize)
+	if length%blocksize != 0 {
+		return false, 0, fmt.Errorf("invalid padding: len(%s) is not a multiple of %d", string(b), blocksize)
 	}
 
 	last := b[length-1 : length]
@@ -53,7 +69,7 @@ func ValidPkcs7(b []byte) (bool, int, error) {
 		return false, 0, errors.New("no padding")
 	}
 
-	if pad > aes.BlockSize {
+	if pad > uint64(blocksize) {
 		return false, 0, errors.New("last byte exceeds blocksize")
 	}
 
@@ -76,3 +76,25 @@ func TestValidPkcs7(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestValidPkcs7Blocksize(t *testing.T) {
+	valid, n, err := ValidPkcs7Blocksize([]byte("ICE\x05\x05\x05\x05\x05"), 8)
+	if !valid || n != 5 || err != nil {
+		t.Errorf("expected valid padding of 5 bytes, got %v %d %v", valid, n, err)
+	}
+
+	_, _, err = ValidPkcs7Blocksize([]byte("ICE BABY\x03\x03\x03"), 8)
+	if err == nil || err.Error() != "invalid padding: len(ICE BABY\x03\x03\x03) is not a multiple of 8" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, _, err = ValidPkcs7Blocksize([]byte("ICE\x09\x09\x09\x09\x09"), 8)
+	if err == nil {
+		t.Error("should fail if the last byte exceeds the blocksize")
+	}
+
+	stripped, err := StripIfValidPkcs7Blocksize([]byte("ICE ICE BABY\x04\x04\x04\x04"), 4)
+	if err != nil || string(stripped) != "ICE ICE BABY" {
+		t.Errorf("expected ICE ICE BABY, got %s (%v)", stripped, err)
+	}
+}
@@ -2,15 +2,17 @@
 package bytes
 
 import (
-	stdBytes "bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"math/bits"
 )
 
-// Popular returns n most popular bytes from a slice. In the event of a
-// tie, returns the bytes occuring latest in the slice.
+// Popular returns the n most popular bytes in a slice, most popular first.
+// Ties go to the lower byte value, as in Histogram.TopN. Fewer than n bytes
+// are returned if the slice doesn't have n distinct values.
 func Popular(b []byte, n int) ([]byte, error) {
 	if len(b) == 0 || n == 0 {
 		return nil, errors.New("invalid argument")
@@ -18,35 +20,7 @@ func Popular(b []byte, n int) ([]byte, error) {
 	if n > len(b) {
 		return nil, errors.New("n exceeds length of slice")
 	}
-
-	count := make([]int, n)
-	popular := make([]byte, n)
-	temp := make([]byte, 1)
-
-	for _, val := range b {
-		temp[0] = val
-
-		// If val already exists in popular, move on
-		if stdBytes.Contains(popular, temp) {
-			continue
-		}
-
-		tempCount := stdBytes.Count(b, temp)
-
-		for i := 0; i < n; i++ {
-			if tempCount > count[i] {
-				// Only set popular[i+1] if popular[i] isn't last member in popular
-				if n-i > 1 {
-					popular[i+1] = popular[i]
-				}
-				popular[i] = temp[0]
-				count[i] = tempCount
-				break
-			}
-		}
-
-	}
-	return popular, nil
+	return NewHistogram(b).TopN(n), nil
 }
 
 func HexToBase64(s string) (string, error) {
@@ -117,6 +91,25 @@ func HasRepeatedBlock(b []byte, blocksize int) (bool, error) {
 	return false, nil
 }
 
+// RepeatedBlocks returns the number of blocks in a slice that repeat an
+// earlier block
+func RepeatedBlocks(b []byte, blocksize int) (int, error) {
+	blocks, err := SplitIntoBlocks(b, blocksize)
+	if err != nil {
+		return 0, err
+	}
+	m := make(map[string]bool)
+
+	repeats := 0
+	for _, block := range blocks {
+		if m[string(block)] {
+			repeats++
+		}
+		m[string(block)] = true
+	}
+	return repeats, nil
+}
+
 // HammingDistance returns the Hamming distance between two byte slices
 func HammingDistance(a, b []byte) (int, error) {
 	aLen := len(a)
@@ -126,12 +119,15 @@ func HammingDistance(a, b []byte) (int, error) {
 		return -1, errors.New("length mismatch")
 	}
 
+	// Count the differing bits 8 bytes at a time
 	dist := 0
-	c := make([]byte, aLen)
-
-	for i := range a {
-		c[i] = a[i] ^ b[i]
-		dist += HammingWeight(int(c[i]))
+	i := 0
+	for ; i+8 <= aLen; i += 8 {
+		x := binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:])
+		dist += bits.OnesCount64(x)
+	}
+	for ; i < aLen; i++ {
+		dist += bits.OnesCount8(a[i] ^ b[i])
 	}
 	return dist, nil
 }
@@ -1,6 +1,7 @@
 package bytes
 
 import (
+	"math/bits"
 	"testing"
 )
 
@@ -60,6 +61,18 @@ func TestPopular(t *testing.T) {
 	if err.Error() != ("n exceeds length of slice") {
 		t.Error("should fail if n > len(b)")
 	}
+
+	// Ties go to the lower byte value, and none are lost
+	result, _ = Popular([]byte{5, 0, 7, 7, 0, 5, 9}, 4)
+	if string(result) != string([]byte{0, 5, 7, 9}) {
+		t.Errorf("expected [0 5 7 9], got %v", result)
+	}
+
+	// Only distinct bytes are returned
+	result, _ = Popular([]byte{4, 4, 4}, 2)
+	if string(result) != string([]byte{4}) {
+		t.Errorf("expected [4], got %v", result)
+	}
 }
 
 func TestSplitIntoBlocks(t *testing.T) {
@@ -94,4 +107,60 @@ func TestHammingDistance(t *testing.T) {
 	if dist != 37 {
 		t.Fail()
 	}
+
+	// Lengths either side of the 8-byte word size
+	for n := 0; n < 40; n++ {
+		a, _ := Random(n)
+		b, _ := Random(n)
+		dist, err := HammingDistance(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := hammingBytes(a, b); dist != expected {
+			t.Errorf("length %d: expected %d, got %d", n, expected, dist)
+		}
+	}
+
+	if _, err := HammingDistance([]byte("a"), []byte("ab")); err == nil {
+		t.Error("should fail on length mismatch")
+	}
+}
+
+// hammingBytes is the byte-at-a-time Hamming distance, for comparison
+func hammingBytes(a, b []byte) int {
+	dist := 0
+	for i := range a {
+		dist += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return dist
+}
+
+func BenchmarkHammingDistance(b *testing.B) {
+	x, _ := Random(4096)
+	y, _ := Random(4096)
+	b.SetBytes(int64(len(x)))
+	for i := 0; i < b.N; i++ {
+		HammingDistance(x, y)
+	}
+}
+
+func BenchmarkHammingDistanceBytes(b *testing.B) {
+	x, _ := Random(4096)
+	y, _ := Random(4096)
+	b.SetBytes(int64(len(x)))
+	for i := 0; i < b.N; i++ {
+		hammingBytes(x, y)
+	}
+}
+
+func TestRepeatedBlocks(t *testing.T) {
+	n, _ := RepeatedBlocks([]byte("AAAABBBBAAAACCCCAAAA"), 4)
+	if n != 2 {
+		t.Errorf("expected 2 repeated blocks, got %d", n)
+	}
+
+	n, _ = RepeatedBlocks([]byte("AAAABBBBCCCC"), 4)
+	if n != 0 {
+		t.Errorf("expected no repeated blocks, got %d", n)
+	}
 }
@@ -0,0 +1,103 @@
+package bytes
+
+import (
+	"math"
+	"sort"
+)
+
+// Histogram counts how many times each byte value occurs
+type Histogram struct {
+	Counts [256]int
+	Total  int
+}
+
+// NewHistogram counts the bytes of b in one pass
+func NewHistogram(b []byte) *Histogram {
+	h := new(Histogram)
+	h.Add(b)
+	return h
+}
+
+// Add counts the bytes of b on top of what h already holds
+func (h *Histogram) Add(b []byte) {
+	for _, c := range b {
+		h.Counts[c]++
+	}
+	h.Total += len(b)
+}
+
+// TopN returns up to n byte values that occur, most frequent first. Ties
+// go to the lower byte value, whatever order the bytes were added in.
+func (h *Histogram) TopN(n int) []byte {
+	var present []byte
+	for i, count := range h.Counts {
+		if count > 0 {
+			present = append(present, byte(i))
+		}
+	}
+	sort.SliceStable(present, func(i, j int) bool {
+		return h.Counts[present[i]] > h.Counts[present[j]]
+	})
+	if n < len(present) {
+		present = present[:n]
+	}
+	return present
+}
+
+// Entropy returns the Shannon entropy of the byte distribution in bits per
+// byte, from 0 for a single repeated value to 8 for uniformly random bytes
+func (h *Histogram) Entropy() float64 {
+	if h.Total == 0 {
+		return 0
+	}
+	entropy := 0.0
+	for _, count := range h.Counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(h.Total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// chiSquaredFloor is the smallest expected probability ChiSquaredAgainst
+// uses, so a byte the model never expects costs a lot instead of making
+// the statistic infinite
+const chiSquaredFloor = 1e-6
+
+// ChiSquaredAgainst returns Pearson's chi-squared statistic comparing the
+// counts to the byte probabilities in model. model doesn't have to sum to
+// one. Lower values mean a closer fit.
+func (h *Histogram) ChiSquaredAgainst(model [256]float64) float64 {
+	sum := 0.0
+	for _, p := range model {
+		sum += p
+	}
+	if h.Total == 0 || sum <= 0 {
+		return 0
+	}
+
+	chi := 0.0
+	for i, count := range h.Counts {
+		p := math.Max(model[i]/sum, chiSquaredFloor)
+		expected := p * float64(h.Total)
+		d := float64(count) - expected
+		chi += d * d / expected
+	}
+	return chi
+}
+
+// IndexOfCoincidence returns the probability that two bytes drawn without
+// replacement are equal. It's about 1/256 for random bytes and much higher
+// for text, whatever single-byte substitution was applied to it.
+func (h *Histogram) IndexOfCoincidence() float64 {
+	if h.Total < 2 {
+		return 0
+	}
+	same := 0
+	for _, count := range h.Counts {
+		same += count * (count - 1)
+	}
+	return float64(same) / float64(h.Total*(h.Total-1))
+}
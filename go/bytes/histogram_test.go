@@ -0,0 +1,87 @@
+package bytes
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]byte("abracadabra"))
+	if h.Total != 11 || h.Counts['a'] != 5 || h.Counts['z'] != 0 {
+		t.Errorf("unexpected counts %d, %d, %d", h.Total, h.Counts['a'], h.Counts['z'])
+	}
+
+	// b and r tie, so the lower value comes first
+	if top := h.TopN(3); string(top) != "abr" {
+		t.Errorf("expected \"abr\", got %q", top)
+	}
+	if top := h.TopN(10); string(top) != "abrcd" {
+		t.Errorf("expected only bytes that occur, got %q", top)
+	}
+
+	h.Add([]byte("zz"))
+	if h.Total != 13 || h.Counts['z'] != 2 {
+		t.Errorf("Add didn't count, got %d and %d", h.Total, h.Counts['z'])
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	if e := NewHistogram([]byte("aaaa")).Entropy(); e != 0 {
+		t.Errorf("expected 0 for a single value, got %f", e)
+	}
+	if e := NewHistogram([]byte("abcdabcd")).Entropy(); e != 2 {
+		t.Errorf("expected 2 bits for 4 even values, got %f", e)
+	}
+
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	if e := NewHistogram(all).Entropy(); e != 8 {
+		t.Errorf("expected 8 bits for every byte value, got %f", e)
+	}
+}
+
+func TestChiSquaredAgainst(t *testing.T) {
+	var model [256]float64
+	model['a'] = 3
+	model['b'] = 1
+
+	if chi := NewHistogram([]byte("aaab")).ChiSquaredAgainst(model); chi > .01 {
+		t.Errorf("expected a perfect fit, got %f", chi)
+	}
+	fit := NewHistogram([]byte("aaba")).ChiSquaredAgainst(model)
+	worse := NewHistogram([]byte("abbb")).ChiSquaredAgainst(model)
+	unexpected := NewHistogram([]byte("aaac")).ChiSquaredAgainst(model)
+	if !(fit < worse && worse < unexpected) || math.IsInf(unexpected, 0) {
+		t.Errorf("expected increasing finite statistics, got %f, %f, %f", fit, worse, unexpected)
+	}
+}
+
+func TestIndexOfCoincidence(t *testing.T) {
+	text := []byte("It was the best of times, it was the worst of times")
+	ic := NewHistogram(text).IndexOfCoincidence()
+
+	// A substitution doesn't change it
+	shifted, _ := XorRepeatingKey(text, []byte{0x5a})
+	if shiftedIC := NewHistogram(shifted).IndexOfCoincidence(); shiftedIC != ic {
+		t.Errorf("expected %f after XOR, got %f", ic, shiftedIC)
+	}
+
+	random, _ := Random(4096)
+	if randomIC := NewHistogram(random).IndexOfCoincidence(); randomIC > ic/3 {
+		t.Errorf("expected random bytes to score well below text, got %f vs %f", randomIC, ic)
+	}
+
+	if NewHistogram([]byte("a")).IndexOfCoincidence() != 0 {
+		t.Error("expected 0 for a single byte")
+	}
+}
+
+func BenchmarkPopular(b *testing.B) {
+	data, _ := Random(4096)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Popular(data, 1)
+	}
+}
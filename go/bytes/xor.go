@@ -1,6 +1,7 @@
 package bytes
 
 import (
+	"encoding/binary"
 	"errors"
 )
 
@@ -20,12 +21,38 @@ func Xor(b1, b2 []byte) ([]byte, error) {
 	}
 
 	xored := make([]byte, len(b1))
-	for i := range xored {
-		xored[i] = b1[i] ^ b2[i]
-	}
+	xorWords(xored, b1, b2)
 	return xored, nil
 }
 
+// XorInto sets dst to the XOR of two equal-length byte slices without
+// allocating. dst must be at least as long as a and may be a or b.
+func XorInto(dst, a, b []byte) error {
+	if len(a) != len(b) {
+		return errors.New("length mismatch")
+	}
+	if len(dst) < len(a) {
+		return errors.New("destination too short")
+	}
+	xorWords(dst, a, b)
+	return nil
+}
+
+// xorWords XORs a and b into dst 8 bytes at a time, then finishes any tail
+// a byte at a time. The slices must be the same length except dst, which
+// may be longer.
+func xorWords(dst, a, b []byte) {
+	n := len(a)
+	i := 0
+	for ; i+8 <= n; i += 8 {
+		x := binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:])
+		binary.LittleEndian.PutUint64(dst[i:], x)
+	}
+	for ; i < n; i++ {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
 // XorRepeating returns the result of applying a repeating XOR key to a byte slice
 func XorRepeatingKey(b, key []byte) ([]byte, error) {
 	keyLen := len(key)
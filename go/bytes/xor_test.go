@@ -31,6 +31,71 @@ func TestXor(t *testing.T) {
 	}
 }
 
+func TestXorInto(t *testing.T) {
+	for n := 0; n < 40; n++ {
+		a, _ := Random(n)
+		b, _ := Random(n)
+		dst := make([]byte, n)
+		if err := XorInto(dst, a, b); err != nil {
+			t.Fatal(err)
+		}
+		for i := range dst {
+			if dst[i] != a[i]^b[i] {
+				t.Fatalf("length %d: wrong byte at %d", n, i)
+			}
+		}
+
+		// In place
+		XorInto(a, a, b)
+		if string(a) != string(dst) {
+			t.Errorf("length %d: in-place XOR differs", n)
+		}
+	}
+
+	if err := XorInto(make([]byte, 8), []byte("1234"), []byte("123")); err == nil {
+		t.Error("should fail on length mismatch")
+	}
+	if err := XorInto(make([]byte, 3), []byte("1234"), []byte("1234")); err == nil {
+		t.Error("should fail given a short destination")
+	}
+}
+
+// xorBytes is the byte-at-a-time XOR, for comparison
+func xorBytes(dst, a, b []byte) {
+	for i := range a {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+func BenchmarkXor(b *testing.B) {
+	x, _ := Random(4096)
+	y, _ := Random(4096)
+	b.SetBytes(int64(len(x)))
+	for i := 0; i < b.N; i++ {
+		Xor(x, y)
+	}
+}
+
+func BenchmarkXorInto(b *testing.B) {
+	x, _ := Random(4096)
+	y, _ := Random(4096)
+	dst := make([]byte, len(x))
+	b.SetBytes(int64(len(x)))
+	for i := 0; i < b.N; i++ {
+		XorInto(dst, x, y)
+	}
+}
+
+func BenchmarkXorBytes(b *testing.B) {
+	x, _ := Random(4096)
+	y, _ := Random(4096)
+	dst := make([]byte, len(x))
+	b.SetBytes(int64(len(x)))
+	for i := 0; i < b.N; i++ {
+		xorBytes(dst, x, y)
+	}
+}
+
 func TestXorRepeatingKey(t *testing.T) {
 	b, _ := hex.DecodeString("1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736")
 	expected := []byte{99, 79, 79, 75, 73, 78, 71, 0, 109, 99, 7, 83, 0, 76, 73, 75, 69, 0, 65, 0, 80, 79, 85, 78, 68, 0, 79, 70, 0, 66, 65, 67, 79, 78}
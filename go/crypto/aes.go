@@ -6,15 +6,17 @@ import (
 	"crypto/cipher"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
 	"time"
 
 	"github.com/taravancil/cryptopals/blocks"
 	"github.com/taravancil/cryptopals/bytes"
+	"github.com/taravancil/cryptopals/rijndael"
 )
 
-var GlobalAesKey []byte
-
 type ecb struct {
 	b         cipher.Block
 	blockSize int
@@ -74,70 +76,354 @@ func (d *ecbEncrypter) CryptBlocks(dst, src []byte) {
 	}
 }
 
+// EcbEncrypt pads a plaintext with PKCS#7 and encrypts it under AES ECB
 func EcbEncrypt(plaintext, key []byte) ([]byte, error) {
-	plaintext, _ = blocks.Pkcs7(plaintext, aes.BlockSize)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	ciphertext := make([]byte, len(plaintext))
-	encrypter := NewECBEncrypter(block)
-	encrypter.CryptBlocks(ciphertext, plaintext)
-	return ciphertext, nil
+	return EcbEncryptBlock(plaintext, block)
 }
 
+// EcbDecrypt decrypts a ciphertext under AES ECB. The padding is left on.
 func EcbDecrypt(ciphertext, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	plaintext := make([]byte, len(ciphertext))
-	decrypter := NewECBDecrypter(block)
-	decrypter.CryptBlocks(plaintext, ciphertext)
-	return plaintext, nil
+	return EcbDecryptBlock(ciphertext, block)
 }
 
+// CbcEncrypt pads a plaintext with PKCS#7 and encrypts it under AES CBC
 func CbcEncrypt(plaintext, key, iv []byte) ([]byte, error) {
-	plaintext, _ = blocks.Pkcs7(plaintext, aes.BlockSize)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
+	return CbcEncryptBlock(plaintext, block, iv)
+}
+
+// CbcDecrypt decrypts a ciphertext under AES CBC. The padding is left on.
+func CbcDecrypt(ciphertext, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return CbcDecryptBlock(ciphertext, block, iv)
+}
+
+// EcbEncryptBlock pads a plaintext with PKCS#7 to b's block size and
+// encrypts it under ECB mode with b
+func EcbEncryptBlock(plaintext []byte, b cipher.Block) ([]byte, error) {
+	plaintext, err := blocks.Pkcs7(plaintext, b.BlockSize())
+	if err != nil {
+		return nil, err
+	}
 
 	ciphertext := make([]byte, len(plaintext))
-	encrypter := cipher.NewCBCEncrypter(block, iv)
+	encrypter := NewECBEncrypter(b)
 	encrypter.CryptBlocks(ciphertext, plaintext)
 	return ciphertext, nil
 }
 
-func CbcDecrypt(ciphertext, key, iv []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// EcbDecryptBlock decrypts a ciphertext under ECB mode with b. The padding
+// is left on.
+func EcbDecryptBlock(ciphertext []byte, b cipher.Block) ([]byte, error) {
+	if len(ciphertext)%b.BlockSize() != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of block size %d", b.BlockSize())
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	decrypter := NewECBDecrypter(b)
+	decrypter.CryptBlocks(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// CbcEncryptBlock pads a plaintext with PKCS#7 to b's block size and
+// encrypts it under CBC mode with b
+func CbcEncryptBlock(plaintext []byte, b cipher.Block, iv []byte) ([]byte, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("iv must be %d bytes", b.BlockSize())
+	}
+	plaintext, err := blocks.Pkcs7(plaintext, b.BlockSize())
 	if err != nil {
 		return nil, err
 	}
 
+	ciphertext := make([]byte, len(plaintext))
+	encrypter := cipher.NewCBCEncrypter(b, iv)
+	encrypter.CryptBlocks(ciphertext, plaintext)
+	return ciphertext, nil
+}
+
+// CbcDecryptBlock decrypts a ciphertext under CBC mode with b. The padding
+// is left on.
+func CbcDecryptBlock(ciphertext []byte, b cipher.Block, iv []byte) ([]byte, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("iv must be %d bytes", b.BlockSize())
+	}
+	if len(ciphertext)%b.BlockSize() != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of block size %d", b.BlockSize())
+	}
+
 	plaintext := make([]byte, len(ciphertext))
-	decrypter := cipher.NewCBCDecrypter(block, iv)
+	decrypter := cipher.NewCBCDecrypter(b, iv)
 	decrypter.CryptBlocks(plaintext, ciphertext)
 	return plaintext, nil
 }
 
+// CbcEncryptKeyIV encrypts under AES CBC, using the key as the IV
+func CbcEncryptKeyIV(plaintext, key []byte) ([]byte, error) {
+	return CbcEncrypt(plaintext, key, key)
+}
+
+// CbcDecryptKeyIV decrypts under AES CBC, using the key as the IV
+func CbcDecryptKeyIV(ciphertext, key []byte) ([]byte, error) {
+	return CbcDecrypt(ciphertext, key, key)
+}
+
+// HighASCIIError is returned by ReceiveKeyIV when a decrypted message
+// contains bytes outside of ASCII. It carries the offending plaintext.
+type HighASCIIError struct {
+	Plaintext []byte
+}
+
+func (e *HighASCIIError) Error() string {
+	return fmt.Sprintf("invalid message: %q", e.Plaintext)
+}
+
+// ReceiveKeyIV decrypts a message encrypted with CbcEncryptKeyIV and
+// rejects it with a *HighASCIIError if it contains high-ASCII bytes
+func ReceiveKeyIV(ciphertext, key []byte) error {
+	plaintext, err := CbcDecryptKeyIV(ciphertext, key)
+	if err != nil {
+		return err
+	}
+	for _, b := range plaintext {
+		if b > 127 {
+			return &HighASCIIError{Plaintext: plaintext}
+		}
+	}
+	return nil
+}
+
+// CTRConfig describes the layout of a CTR mode counter block: Nonce followed
+// by a CounterSize-byte counter, which starts at Initial and is encoded in
+// ByteOrder (big-endian if nil). Counters of 1, 2, 4 or 8 bytes can use any
+// ByteOrder; other sizes only big- or little-endian. The counter wraps
+// around within its width without carrying into the nonce.
+type CTRConfig struct {
+	Nonce       []byte
+	CounterSize int
+	ByteOrder   binary.ByteOrder
+	Initial     uint64
+}
+
+// CTRStream is a CTR mode cipher.Stream that can seek to any offset in its
+// keystream without generating the keystream before it
+type CTRStream struct {
+	b         cipher.Block
+	config    CTRConfig
+	counter   uint64
+	keystream []byte
+	used      int
+	pos       int64
+}
+
+// NewCTR returns a stream that produces the CTR mode keystream of b for a
+// counter block laid out as described by config
+func NewCTR(b cipher.Block, config CTRConfig) (*CTRStream, error) {
+	if config.CounterSize < 1 || config.CounterSize > 8 {
+		return nil, fmt.Errorf("invalid counter size %d", config.CounterSize)
+	}
+	if len(config.Nonce)+config.CounterSize != b.BlockSize() {
+		return nil, fmt.Errorf("nonce and counter are %d bytes, block size is %d", len(config.Nonce)+config.CounterSize, b.BlockSize())
+	}
+	if config.CounterSize < 8 && config.Initial >= 1<<(8*uint(config.CounterSize)) {
+		return nil, fmt.Errorf("initial counter %d exceeds counter size", config.Initial)
+	}
+	switch config.CounterSize {
+	case 1, 2, 4, 8:
+	default:
+		if order := config.ByteOrder; order != nil && order != binary.BigEndian && order != binary.LittleEndian {
+			return nil, fmt.Errorf("a %d-byte counter must be big- or little-endian, not %v", config.CounterSize, order)
+		}
+	}
+
+	keystream := make([]byte, b.BlockSize())
+	return &CTRStream{
+		b:         b,
+		config:    config,
+		counter:   config.Initial,
+		keystream: keystream,
+		used:      len(keystream),
+	}, nil
+}
+
+// counterBlock returns the counter block for the current value of the
+// counter
+func (c *CTRStream) counterBlock() []byte {
+	size := c.config.CounterSize
+	block := make([]byte, len(c.config.Nonce)+size)
+	copy(block, c.config.Nonce)
+
+	order := c.config.ByteOrder
+	if order == nil {
+		order = binary.BigEndian
+	}
+
+	dst := block[len(c.config.Nonce):]
+	switch size {
+	case 1:
+		dst[0] = byte(c.counter)
+	case 2:
+		order.PutUint16(dst, uint16(c.counter))
+	case 4:
+		order.PutUint32(dst, uint32(c.counter))
+	case 8:
+		order.PutUint64(dst, c.counter)
+	default:
+		// NewCTR only allows big- and little-endian for other sizes
+		counter := make([]byte, 8)
+		if order == binary.LittleEndian {
+			binary.LittleEndian.PutUint64(counter, c.counter)
+			copy(dst, counter[:size])
+		} else {
+			binary.BigEndian.PutUint64(counter, c.counter)
+			copy(dst, counter[8-size:])
+		}
+	}
+	return block
+}
+
+// wrap reduces a counter value to the width of the counter
+func (c *CTRStream) wrap(counter uint64) uint64 {
+	if c.config.CounterSize < 8 {
+		counter &= 1<<(8*uint(c.config.CounterSize)) - 1
+	}
+	return counter
+}
+
+// refill encrypts the current counter block into the keystream buffer and
+// increments the counter
+func (c *CTRStream) refill() {
+	c.b.Encrypt(c.keystream, c.counterBlock())
+	c.used = 0
+	c.counter = c.wrap(c.counter + 1)
+}
+
+func (c *CTRStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	for i := range src {
+		if c.used == len(c.keystream) {
+			c.refill()
+		}
+		dst[i] = src[i] ^ c.keystream[c.used]
+		c.used++
+	}
+	c.pos += int64(len(src))
+}
+
+// Seek sets the offset in the keystream of the next byte XORKeyStream uses,
+// implementing io.Seeker. The keystream has no end, so io.SeekEnd is not
+// supported.
+func (c *CTRStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.pos
+	default:
+		return c.pos, fmt.Errorf("unsupported whence %d", whence)
+	}
+	if offset < 0 {
+		return c.pos, errors.New("negative offset")
+	}
+
+	blockSize := int64(len(c.keystream))
+	c.counter = c.wrap(c.config.Initial + uint64(offset/blockSize))
+	c.used = len(c.keystream)
+	if offset%blockSize != 0 {
+		c.refill()
+		c.used = int(offset % blockSize)
+	}
+	c.pos = offset
+	return offset, nil
+}
+
 // Ctr is as utility function for setting up a keystream for AES in
-// CTR mode
+// CTR mode, with a 64-bit little-endian nonce followed by a 64-bit
+// little-endian counter starting at 0
 func Ctr(nonce uint64, key []byte) (cipher.Stream, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
-	iv := new(stdBytes.Buffer)
-	ctr := 0
-	_ = binary.Write(iv, binary.LittleEndian, nonce)
-	_ = binary.Write(iv, binary.LittleEndian, uint64(ctr))
+	return NewCTR(block, ctrPreset(nonce))
+}
 
-	return cipher.NewCTR(block, iv.Bytes()), nil
+// ctrPreset returns the counter block layout used by Ctr
+func ctrPreset(nonce uint64) CTRConfig {
+	n := make([]byte, 8)
+	binary.LittleEndian.PutUint64(n, nonce)
+	return CTRConfig{
+		Nonce:       n,
+		CounterSize: 8,
+		ByteOrder:   binary.LittleEndian,
+	}
+}
+
+// Edit returns a copy of a ciphertext encrypted with Ctr under key and a
+// nonce of 0, with the plaintext at offset replaced by newtext. Only the
+// keystream covering newtext is generated. newtext may extend past the end
+// of the ciphertext.
+func Edit(ciphertext, key []byte, offset int, newtext []byte) ([]byte, error) {
+	if offset < 0 || offset > len(ciphertext) {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := NewCTR(block, ctrPreset(0))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stream.Seek(int64(offset), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	edited := make([]byte, len(ciphertext))
+	copy(edited, ciphertext)
+	if end := offset + len(newtext); end > len(edited) {
+		edited = append(edited, make([]byte, end-len(edited))...)
+	}
+	stream.XORKeyStream(edited[offset:offset+len(newtext)], newtext)
+	return edited, nil
+}
+
+type mtStream struct {
+	mt *MT19937
+}
+
+// MTStream returns a stream cipher keyed by a 16-bit seed, whose keystream is
+// the output of MT19937 serialized as by MT19937.Read
+func MTStream(key uint16) cipher.Stream {
+	return &mtStream{mt: NewMT19937(uint32(key))}
+}
+
+func (s *mtStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	keystream := make([]byte, len(src))
+	s.mt.Read(keystream)
+	for i := range src {
+		dst[i] = src[i] ^ keystream[i]
+	}
 }
 
 // NewAesKey generates a random AES key
@@ -146,6 +432,13 @@ func NewAesKey() []byte {
 	return key
 }
 
+// NewAesRounds returns AES reduced to the given number of rounds, for
+// attacks that don't work against the full cipher. The last round skips
+// MixColumns like the last round of full AES.
+func NewAesRounds(key []byte, rounds int) (cipher.Block, error) {
+	return rijndael.NewCipherRounds(key, rounds)
+}
+
 var seed = rand.NewSource(time.Now().UnixNano())
 var r = rand.New(seed)
 
@@ -167,6 +460,9 @@ func RandomAesMode() (cipher.BlockMode, string, error) {
 // AesOracle prepends and appends 5-10 random bytes to a plaintext,
 // encrypts the plaintext under a predetermined BlockMode, then
 // returns the detected BlockMode
+//
+// Deprecated: AesOracle only tells ECB from CBC for a BlockMode it holds.
+// Use DetectMode, which works on any black-box oracle.
 func AesOracle(plaintext []byte, encrypter cipher.BlockMode) string {
 	// Generate random bytes to prepend/append to plaintext
 	prependBytes, _ := bytes.Random(r.Intn(5) + 5)
@@ -231,17 +527,3 @@ func AppendSecretEncryptEcb(plaintext, key []byte, prefix bool) []byte {
 	ciphertext, _ := EcbEncrypt(plaintext, key)
 	return ciphertext
 }
-
-// CbcValidPadding decrypts a CBC-encrypted ciphertext and detects if the plaintext was padded correctly.
-func CbcPaddingOracle(ciphertext, iv []byte) (bool, error) {
-	plaintext, err := CbcDecrypt(ciphertext, GlobalAesKey, iv)
-	if err != nil {
-		return false, err
-	}
-
-	valid, _, _ := blocks.ValidPkcs7(plaintext)
-	if valid {
-		return true, nil
-	}
-	return false, nil
-}
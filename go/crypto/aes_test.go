@@ -1,7 +1,16 @@
 package crypto
 
 import (
+	stdBytes "bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
 	"testing"
+
+	"github.com/taravancil/cryptopals/blocks"
 )
 
 func TestAesOracle(t *testing.T) {
@@ -16,3 +25,303 @@ func TestAesOracle(t *testing.T) {
 		t.Errorf("expected %s, got %s", actualMode, guessedMode)
 	}
 }
+
+func TestMTStream(t *testing.T) {
+	plaintext := []byte("Like your mother and your father too.")
+	ciphertext := make([]byte, len(plaintext))
+	decrypted := make([]byte, len(plaintext))
+
+	// Encrypting in pieces should use one continuous keystream
+	stream := MTStream(1234)
+	stream.XORKeyStream(ciphertext[:5], plaintext[:5])
+	stream.XORKeyStream(ciphertext[5:], plaintext[5:])
+
+	MTStream(1234).XORKeyStream(decrypted, ciphertext)
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("expected %s, got %s", plaintext, decrypted)
+	}
+
+	MTStream(1235).XORKeyStream(decrypted, ciphertext)
+	if string(decrypted) == string(plaintext) {
+		t.Error("a different key should not decrypt the ciphertext")
+	}
+}
+
+// NIST SP 800-38A F.5.1 and F.5.5
+var ctrVectors = []struct {
+	key, ciphertext string
+}{
+	{
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		"874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee",
+	},
+	{
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		"601ec313775789a5b7a7f504bbf3d228f443e3ca4d62b59aca84e990cacaf5c52b0930daa23de94ce87017ba2d84988ddfc9c58db67aada613c2dd08457941a6",
+	},
+}
+
+const ctrVectorPlaintext = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+func TestNewCTR(t *testing.T) {
+	plaintext, _ := hex.DecodeString(ctrVectorPlaintext)
+	nonce, _ := hex.DecodeString("f0f1f2f3f4f5f6f7")
+
+	for _, v := range ctrVectors {
+		key, _ := hex.DecodeString(v.key)
+		block, _ := aes.NewCipher(key)
+
+		// The initial counter block is f0f1...feff
+		stream, err := NewCTR(block, CTRConfig{
+			Nonce:       nonce,
+			CounterSize: 8,
+			Initial:     0xf8f9fafbfcfdfeff,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext := make([]byte, len(plaintext))
+		stream.XORKeyStream(ciphertext[:7], plaintext[:7])
+		stream.XORKeyStream(ciphertext[7:], plaintext[7:])
+		if hex.EncodeToString(ciphertext) != v.ciphertext {
+			t.Errorf("key %s: expected %s, got %x", v.key, v.ciphertext, ciphertext)
+		}
+	}
+}
+
+func TestNewCTRLayout(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	block, _ := aes.NewCipher(key)
+	nonce := []byte("twelve bytes")
+
+	// A 32-bit big-endian counter should wrap to 0 without touching the nonce
+	stream, err := NewCTR(block, CTRConfig{Nonce: nonce, CounterSize: 4, Initial: 0xffffffff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystream := make([]byte, 32)
+	stream.XORKeyStream(keystream, keystream)
+
+	expected := make([]byte, 32)
+	block.Encrypt(expected, append(nonce, 0xff, 0xff, 0xff, 0xff))
+	block.Encrypt(expected[16:], append(nonce, 0, 0, 0, 0))
+	if !stdBytes.Equal(keystream, expected) {
+		t.Errorf("expected %x, got %x", expected, keystream)
+	}
+
+	// The Ctr preset uses a little-endian nonce and counter
+	preset, _ := Ctr(1, key)
+	preset.XORKeyStream(keystream, make([]byte, 32))
+	block.Encrypt(expected, []byte("\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	block.Encrypt(expected[16:], []byte("\x01\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00"))
+	if !stdBytes.Equal(keystream, expected) {
+		t.Errorf("expected %x, got %x", expected, keystream)
+	}
+
+	_, err = NewCTR(block, CTRConfig{Nonce: nonce, CounterSize: 8})
+	if err == nil {
+		t.Error("should fail if the nonce and counter don't fill a block")
+	}
+	_, err = NewCTR(block, CTRConfig{Nonce: nonce, CounterSize: 4, Initial: 1 << 32})
+	if err == nil {
+		t.Error("should fail if the initial counter doesn't fit")
+	}
+}
+
+// wordSwapped is a byte order that stores the halves of each value in the
+// opposite order to big-endian
+type wordSwapped struct{}
+
+func (wordSwapped) Uint16(b []byte) uint16 { return binary.LittleEndian.Uint16(b) }
+func (wordSwapped) Uint32(b []byte) uint32 {
+	return binary.BigEndian.Uint32(append(append([]byte{}, b[2:4]...), b[:2]...))
+}
+func (wordSwapped) Uint64(b []byte) uint64 {
+	return binary.BigEndian.Uint64(append(append([]byte{}, b[4:8]...), b[:4]...))
+}
+func (wordSwapped) PutUint16(b []byte, v uint16) { binary.LittleEndian.PutUint16(b, v) }
+func (wordSwapped) PutUint32(b []byte, v uint32) {
+	binary.BigEndian.PutUint16(b, uint16(v))
+	binary.BigEndian.PutUint16(b[2:], uint16(v>>16))
+}
+func (wordSwapped) PutUint64(b []byte, v uint64) {
+	binary.BigEndian.PutUint32(b, uint32(v))
+	binary.BigEndian.PutUint32(b[4:], uint32(v>>32))
+}
+func (wordSwapped) String() string { return "wordSwapped" }
+
+func TestNewCTRByteOrder(t *testing.T) {
+	block, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	nonce := []byte("twelve bytes")
+
+	// The counter goes through the configured ByteOrder, whatever it is
+	stream, err := NewCTR(block, CTRConfig{Nonce: nonce, CounterSize: 4, ByteOrder: wordSwapped{}, Initial: 0x01020304})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystream := make([]byte, 16)
+	stream.XORKeyStream(keystream, keystream)
+
+	expected := make([]byte, 16)
+	block.Encrypt(expected, append(nonce, 0x03, 0x04, 0x01, 0x02))
+	if !stdBytes.Equal(keystream, expected) {
+		t.Errorf("expected %x, got %x", expected, keystream)
+	}
+
+	// A 3-byte counter has no ByteOrder method to write it
+	_, err = NewCTR(block, CTRConfig{Nonce: []byte("thirteen byte"), CounterSize: 3, ByteOrder: wordSwapped{}})
+	if err == nil {
+		t.Error("should fail given an unsupported byte order for a 3-byte counter")
+	}
+}
+
+func TestCTRStreamSeek(t *testing.T) {
+	block, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	config := CTRConfig{Nonce: []byte("twelve bytes"), CounterSize: 4, Initial: 0xfffffffe}
+
+	stream, _ := NewCTR(block, config)
+	keystream := make([]byte, 100)
+	stream.XORKeyStream(keystream, keystream)
+
+	for _, offset := range []int64{0, 1, 16, 17, 47, 99} {
+		stream, _ := NewCTR(block, config)
+		pos, err := stream.Seek(offset, io.SeekStart)
+		if err != nil || pos != offset {
+			t.Fatalf("Seek(%d) = %d, %v", offset, pos, err)
+		}
+
+		b := make([]byte, 100-offset)
+		stream.XORKeyStream(b, b)
+		if !stdBytes.Equal(b, keystream[offset:]) {
+			t.Errorf("offset %d: keystream differs", offset)
+		}
+	}
+
+	stream, _ = NewCTR(block, config)
+	stream.XORKeyStream(make([]byte, 20), make([]byte, 20))
+	pos, _ := stream.Seek(-3, io.SeekCurrent)
+	if pos != 17 {
+		t.Errorf("expected position 17, got %d", pos)
+	}
+	if _, err := stream.Seek(0, io.SeekEnd); err == nil {
+		t.Error("should fail to seek relative to the end")
+	}
+	if _, err := stream.Seek(-1, io.SeekStart); err == nil {
+		t.Error("should fail to seek to a negative offset")
+	}
+}
+
+func TestEdit(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	plaintext := []byte("Like your mother and your father too.")
+	ciphertext := make([]byte, len(plaintext))
+	stream, _ := Ctr(0, key)
+	stream.XORKeyStream(ciphertext, plaintext)
+
+	edited, err := Edit(ciphertext, key, 10, []byte("MOTHER"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := make([]byte, len(edited))
+	stream, _ = Ctr(0, key)
+	stream.XORKeyStream(decrypted, edited)
+	if string(decrypted) != "Like your MOTHER and your father too." {
+		t.Errorf("unexpected plaintext %s", decrypted)
+	}
+
+	// Editing past the end extends the ciphertext
+	edited, _ = Edit(ciphertext, key, len(ciphertext), []byte(" Yes"))
+	if len(edited) != len(ciphertext)+4 {
+		t.Errorf("expected %d bytes, got %d", len(ciphertext)+4, len(edited))
+	}
+
+	if _, err := Edit(ciphertext, key, len(ciphertext)+1, []byte("x")); err == nil {
+		t.Error("should fail given an offset past the end")
+	}
+}
+
+func TestReceiveKeyIV(t *testing.T) {
+	key := NewAesKey()
+	ciphertext, _ := CbcEncryptKeyIV([]byte("comment1=cooking%20MCs;userdata=hi"), key)
+	if err := ReceiveKeyIV(ciphertext, key); err != nil {
+		t.Errorf("expected an ASCII message to be accepted, got %v", err)
+	}
+
+	ciphertext, _ = CbcEncryptKeyIV([]byte("caf\xc3\xa9"), key)
+	err := ReceiveKeyIV(ciphertext, key)
+	asciiErr, ok := err.(*HighASCIIError)
+	if !ok {
+		t.Fatalf("expected a *HighASCIIError, got %v", err)
+	}
+	if string(asciiErr.Plaintext[:5]) != "caf\xc3\xa9" {
+		t.Errorf("error should carry the plaintext, got %q", asciiErr.Plaintext)
+	}
+}
+
+func TestModesWithBlock(t *testing.T) {
+	desBlock, _ := des.NewCipher([]byte("8bytekey"))
+	tripleDES, _ := des.NewTripleDESCipher([]byte("twenty-four byte key 3DE"))
+	toy, _ := NewToyCipher([]byte("8bytekey"))
+	aesBlock, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	plaintext := []byte("Like your mother and your father too.")
+
+	for _, b := range []cipher.Block{desBlock, tripleDES, toy, aesBlock} {
+		size := b.BlockSize()
+		iv := stdBytes.Repeat([]byte{7}, size)
+
+		ciphertext, err := EcbEncryptBlock(plaintext, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ciphertext)%size != 0 || len(ciphertext) < len(plaintext) {
+			t.Errorf("unexpected ciphertext length %d for block size %d", len(ciphertext), size)
+		}
+		decrypted, _ := EcbDecryptBlock(ciphertext, b)
+		stripped, err := blocks.StripIfValidPkcs7Blocksize(decrypted, size)
+		if err != nil || string(stripped) != string(plaintext) {
+			t.Errorf("ECB with block size %d: expected %s, got %s (%v)", size, plaintext, stripped, err)
+		}
+
+		ciphertext, err = CbcEncryptBlock(plaintext, b, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, _ = CbcDecryptBlock(ciphertext, b, iv)
+		stripped, err = blocks.StripIfValidPkcs7Blocksize(decrypted, size)
+		if err != nil || string(stripped) != string(plaintext) {
+			t.Errorf("CBC with block size %d: expected %s, got %s (%v)", size, plaintext, stripped, err)
+		}
+
+		if _, err := CbcDecryptBlock(ciphertext[1:], b, iv); err == nil {
+			t.Error("should fail given a partial block")
+		}
+		if _, err := CbcEncryptBlock(plaintext, b, iv[1:]); err == nil {
+			t.Error("should fail given an iv of the wrong size")
+		}
+	}
+
+	// The oracle attacks work with 8-byte blocks too
+	oracle := PaddingOracleFunc(func(iv, ciphertext []byte) (bool, error) {
+		plaintext, err := CbcDecryptBlock(ciphertext, desBlock, iv)
+		if err != nil {
+			return false, err
+		}
+		valid, _, _ := blocks.ValidPkcs7Blocksize(plaintext, des.BlockSize)
+		return valid, nil
+	})
+	iv := make([]byte, des.BlockSize)
+	ciphertext, _ := CbcEncryptBlock(plaintext, desBlock, iv)
+	decrypted, err := PaddingOracleDecrypt(oracle, iv, ciphertext)
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("padding oracle with DES: expected %s, got %s (%v)", plaintext, decrypted, err)
+	}
+
+	ecb := EcbSuffixOracleFunc(func(input []byte) ([]byte, error) {
+		return EcbEncryptBlock(append(append([]byte{}, input...), plaintext...), toy)
+	})
+	recovered, err := RecoverEcbSuffix(ecb)
+	if err != nil || string(recovered) != string(plaintext) {
+		t.Errorf("ECB suffix with the toy cipher: expected %s, got %s (%v)", plaintext, recovered, err)
+	}
+}
@@ -1,13 +1,65 @@
 package crypto
 
 import (
+	stdBytes "bytes"
+	"crypto/aes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/taravancil/cryptopals/blocks"
 	"github.com/taravancil/cryptopals/bytes"
 	"github.com/taravancil/cryptopals/utils"
 )
 
+// Scorer rates how likely a candidate plaintext is, higher being more likely
+type Scorer func(plaintext []byte) float64
+
+// EnglishScorer scores a plaintext by the log-likelihood of its bytes
+// appearing in English text
+func EnglishScorer(plaintext []byte) float64 {
+	score := 0.0
+	for _, b := range plaintext {
+		score += utils.EnglishByteScore(b)
+	}
+	return score
+}
+
+// SingleByteXorCandidate is one key tried by BreakSingleByteXor
+type SingleByteXorCandidate struct {
+	Key       byte
+	Plaintext []byte
+	Score     float64
+}
+
+// BreakSingleByteXor decrypts a ciphertext XORed against a single byte
+// under all 256 keys and returns the k candidates that scorer rates
+// highest, best first
+func BreakSingleByteXor(ciphertext []byte, scorer Scorer, k int) ([]SingleByteXorCandidate, error) {
+	if len(ciphertext) == 0 {
+		return nil, errors.New("empty ciphertext")
+	}
+	if k < 1 || k > 256 {
+		return nil, errors.New("k must be between 1 and 256")
+	}
+
+	candidates := make([]SingleByteXorCandidate, 256)
+	for i := range candidates {
+		plaintext, _ := bytes.XorRepeatingKey(ciphertext, []byte{byte(i)})
+		candidates[i] = SingleByteXorCandidate{byte(i), plaintext, scorer(plaintext)}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates[:k], nil
+}
+
 func BreakXorRepeating(ciphertext []byte, keysizes []int) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, errors.New("empty ciphertext")
@@ -28,8 +80,11 @@ func BreakXorRepeating(ciphertext []byte, keysizes []int) ([]byte, error) {
 
 		key := make([]byte, size)
 		for i, block := range transposed {
-			popular, _ := bytes.Popular(block, 1)
-			key[i] = popular[0]
+			best, err := BreakSingleByteXor(block, EnglishScorer, 1)
+			if err != nil {
+				return nil, err
+			}
+			key[i] = best[0].Key
 		}
 
 		decrypted, _ := bytes.XorRepeatingKey(ciphertext, key)
@@ -43,6 +98,122 @@ func BreakXorRepeating(ciphertext []byte, keysizes []int) ([]byte, error) {
 	return plaintext, nil
 }
 
+// CtrHint tells BreakFixedNonceCTR the plaintext byte at Offset in the
+// ciphertext at index Ciphertext
+type CtrHint struct {
+	Ciphertext int
+	Offset     int
+	Plaintext  byte
+}
+
+// HintString returns hints that the plaintext of the ciphertext at index
+// ciphertext contains s at offset
+func HintString(ciphertext, offset int, s string) []CtrHint {
+	hints := make([]CtrHint, len(s))
+	for i := range hints {
+		hints[i] = CtrHint{Ciphertext: ciphertext, Offset: offset + i, Plaintext: s[i]}
+	}
+	return hints
+}
+
+// FixedNonceCtr holds the keystream BreakFixedNonceCTR recovered and the
+// plaintexts it decrypts to. Confidence[i] is the probability, under the
+// plaintext model, that Keystream[i] is correct.
+type FixedNonceCtr struct {
+	Keystream  []byte
+	Confidence []float64
+	Plaintexts [][]byte
+}
+
+// BreakFixedNonceCTR recovers the keystream shared by ciphertexts encrypted
+// under CTR mode with the same key and nonce. Each keystream byte is chosen
+// by decrypting the column of ciphertext bytes at its offset under every
+// candidate and scoring the results as English, so ciphertexts can have any
+// length. Hints override the scoring for the columns they cover, and it's an
+// error for two hints to imply different keystream bytes at one offset.
+func BreakFixedNonceCTR(ciphertexts [][]byte, hints ...CtrHint) (*FixedNonceCtr, error) {
+	if len(ciphertexts) == 0 {
+		return nil, errors.New("no ciphertexts")
+	}
+
+	length := 0
+	for _, c := range ciphertexts {
+		if len(c) > length {
+			length = len(c)
+		}
+	}
+
+	result := &FixedNonceCtr{
+		Keystream:  make([]byte, length),
+		Confidence: make([]float64, length),
+		Plaintexts: make([][]byte, len(ciphertexts)),
+	}
+
+	hinted := make([]bool, length)
+	for _, h := range hints {
+		if h.Ciphertext < 0 || h.Ciphertext >= len(ciphertexts) {
+			return nil, fmt.Errorf("hint for ciphertext %d out of range", h.Ciphertext)
+		}
+		if h.Offset < 0 || h.Offset >= len(ciphertexts[h.Ciphertext]) {
+			return nil, fmt.Errorf("hint for offset %d out of range of ciphertext %d", h.Offset, h.Ciphertext)
+		}
+		k := ciphertexts[h.Ciphertext][h.Offset] ^ h.Plaintext
+		if hinted[h.Offset] && result.Keystream[h.Offset] != k {
+			return nil, fmt.Errorf("hints disagree about the keystream at offset %d", h.Offset)
+		}
+		result.Keystream[h.Offset] = k
+		result.Confidence[h.Offset] = 1
+		hinted[h.Offset] = true
+	}
+
+	column := make([]byte, 0, len(ciphertexts))
+	for i := 0; i < length; i++ {
+		if hinted[i] {
+			continue
+		}
+
+		column = column[:0]
+		for _, c := range ciphertexts {
+			if i < len(c) {
+				column = append(column, c[i])
+			}
+		}
+
+		score := utils.EnglishByteScore
+		if i == 0 {
+			score = utils.EnglishInitialByteScore
+		}
+
+		var scores [256]float64
+		best := 0
+		for k := range scores {
+			for _, b := range column {
+				scores[k] += score(b ^ byte(k))
+			}
+			if scores[k] > scores[best] {
+				best = k
+			}
+		}
+
+		// Normalize the scores, which are log-likelihoods, into the
+		// probability of the best key byte
+		var sum float64
+		for _, score := range scores {
+			sum += math.Exp(score - scores[best])
+		}
+		result.Keystream[i] = byte(best)
+		result.Confidence[i] = 1 / sum
+	}
+
+	for i, c := range ciphertexts {
+		result.Plaintexts[i] = make([]byte, len(c))
+		for j := range c {
+			result.Plaintexts[i][j] = c[j] ^ result.Keystream[j]
+		}
+	}
+	return result, nil
+}
+
 // FindKeysizes returns a slice of n possible keysizes in a given range
 func FindKeysizes(b []byte, n, minSize, maxSize int) ([]int, error) {
 	if len(b) == 0 {
@@ -109,68 +280,510 @@ func FindKeysizes(b []byte, n, minSize, maxSize int) ([]int, error) {
 	return keysizes, nil
 }
 
-func MT19937(seed int) (int, error) {
-	var w, r, s, u, t, b, c, d uint
-	n := 624
-	m := 397
-	w = 32
-	r = 31
-	s = 7
-	u = 11
-	t = 15
-	f := 1812433253
-	a := 0x9908b0df
-	b = 0x9d2c5680
-	d = 0xffffffff
-	c = 0xefc60000
-
-	mt := make([]int, n)
-	index := n + 1
-	lowerMask := (1 << r) - 1
-	upperMask := 1 << r
-
-	init := func(seed int) {
-		index = n
-		mt[0] = seed
-		for i := 1; i < n-1; i++ {
-			mt[i] = int(((f * mt[i-1]) ^ ((mt[i-1] >> (w - 2)) + i)) & lowerMask)
-		}
-	}
-
-	twist := func() {
-		for i := 0; i < n-1; i++ {
-			x := (mt[i] & upperMask) + (mt[(i+1)%n] & lowerMask)
-			xA := x >> 1
-			if (x % 2) != 0 {
-				xA = xA ^ a
-			}
-			mt[i] = mt[(i+m)%n] ^ xA
+// MT19937 parameters, as given in the reference implementation mt19937ar.c
+const (
+	mtN         = 624
+	mtM         = 397
+	mtMatrixA   = 0x9908b0df
+	mtUpperMask = 0x80000000
+	mtLowerMask = 0x7fffffff
+	mtInitMult  = 1812433253
+	mtTemperB   = 0x9d2c5680
+	mtTemperC   = 0xefc60000
+
+	// The seed the reference implementation uses when the generator is
+	// used before being seeded
+	mtDefaultSeed = 5489
+)
+
+// MT19937 is a 32-bit Mersenne Twister. Its state is kept between calls, so
+// consecutive calls to Uint32 return consecutive outputs of the generator.
+// The zero value is seeded with the reference default seed (5489) on first
+// use.
+type MT19937 struct {
+	mt     [mtN]uint32
+	index  int
+	seeded bool
+
+	// Leftover bytes of the last output consumed by Read
+	readVal uint32
+	readPos int
+}
+
+// NewMT19937 returns an MT19937 generator seeded with seed
+func NewMT19937(seed uint32) *MT19937 {
+	m := new(MT19937)
+	m.Seed(seed)
+	return m
+}
+
+// Seed initializes the generator's state from a 32-bit seed (init_genrand)
+func (m *MT19937) Seed(seed uint32) {
+	m.mt[0] = seed
+	for i := 1; i < mtN; i++ {
+		m.mt[i] = mtInitMult*(m.mt[i-1]^(m.mt[i-1]>>30)) + uint32(i)
+	}
+	m.index = mtN
+	m.seeded = true
+	m.readPos = 0
+}
+
+// SeedArray initializes the generator's state from a key of arbitrary
+// length (init_by_array)
+func (m *MT19937) SeedArray(key []uint32) {
+	m.Seed(19650218)
+
+	i, j := 1, 0
+	k := mtN
+	if len(key) > k {
+		k = len(key)
+	}
+	for ; k > 0; k-- {
+		m.mt[i] = (m.mt[i] ^ ((m.mt[i-1] ^ (m.mt[i-1] >> 30)) * 1664525)) + key[j] + uint32(j)
+		i++
+		j++
+		if i >= mtN {
+			m.mt[0] = m.mt[mtN-1]
+			i = 1
+		}
+		if j >= len(key) {
+			j = 0
+		}
+	}
+	for k = mtN - 1; k > 0; k-- {
+		m.mt[i] = (m.mt[i] ^ ((m.mt[i-1] ^ (m.mt[i-1] >> 30)) * 1566083941)) - uint32(i)
+		i++
+		if i >= mtN {
+			m.mt[0] = m.mt[mtN-1]
+			i = 1
+		}
+	}
+	// The MSB is 1, assuring a non-zero initial state
+	m.mt[0] = 0x80000000
+}
+
+// twist generates the next mtN words of the generator's state
+func (m *MT19937) twist() {
+	for i := 0; i < mtN; i++ {
+		x := (m.mt[i] & mtUpperMask) | (m.mt[(i+1)%mtN] & mtLowerMask)
+		xA := x >> 1
+		if x&1 != 0 {
+			xA ^= mtMatrixA
+		}
+		m.mt[i] = m.mt[(i+mtM)%mtN] ^ xA
+	}
+	m.index = 0
+}
+
+// temper applies MT19937's tempering transform to a word of state
+func temper(y uint32) uint32 {
+	y ^= y >> 11
+	y ^= (y << 7) & mtTemperB
+	y ^= (y << 15) & mtTemperC
+	y ^= y >> 18
+	return y
+}
+
+// Uint32 returns the next 32-bit output of the generator (genrand_int32)
+func (m *MT19937) Uint32() uint32 {
+	if !m.seeded {
+		m.Seed(mtDefaultSeed)
+	}
+	if m.index >= mtN {
+		m.twist()
+	}
+
+	y := m.mt[m.index]
+	m.index++
+	return temper(y)
+}
+
+// Uint64 returns a 64-bit value built from the next two 32-bit outputs, the
+// first output forming the high 32 bits
+func (m *MT19937) Uint64() uint64 {
+	hi := uint64(m.Uint32())
+	lo := uint64(m.Uint32())
+	return hi<<32 | lo
+}
+
+// Read fills p with the generator's output, each 32-bit output serialized
+// in little-endian order. Consecutive calls continue the same byte stream.
+// It always returns len(p) and a nil error.
+func (m *MT19937) Read(p []byte) (int, error) {
+	for i := range p {
+		if m.readPos == 0 {
+			m.readVal = m.Uint32()
+			m.readPos = 4
+		}
+		p[i] = byte(m.readVal)
+		m.readVal >>= 8
+		m.readPos--
+	}
+	return len(p), nil
+}
+
+// Source returns a math/rand.Source64 backed by the generator, so it can be
+// passed to rand.New. Seeding the returned Source truncates the seed to its
+// low 32 bits.
+func (m *MT19937) Source() rand.Source64 {
+	return (*mt19937Source)(m)
+}
+
+type mt19937Source MT19937
+
+func (s *mt19937Source) Seed(seed int64) {
+	(*MT19937)(s).Seed(uint32(seed))
+}
+
+func (s *mt19937Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *mt19937Source) Uint64() uint64 {
+	return (*MT19937)(s).Uint64()
+}
+
+// Untemper inverts MT19937's tempering transform, returning the word of
+// state that produced output y
+func Untemper(y uint32) uint32 {
+	y = undoRightShiftXor(y, 18)
+	y = undoLeftShiftXor(y, 15, mtTemperC)
+	y = undoLeftShiftXor(y, 7, mtTemperB)
+	y = undoRightShiftXor(y, 11)
+	return y
+}
+
+// undoRightShiftXor inverts y ^= y >> shift. Each pass recovers another
+// shift bits, starting from the high bits, which are left untouched.
+func undoRightShiftXor(y uint32, shift uint) uint32 {
+	x := y
+	for i := uint(0); i < 32; i += shift {
+		x = y ^ (x >> shift)
+	}
+	return x
+}
+
+// undoLeftShiftXor inverts y ^= (y << shift) & mask
+func undoLeftShiftXor(y uint32, shift uint, mask uint32) uint32 {
+	x := y
+	for i := uint(0); i < 32; i += shift {
+		x = y ^ ((x << shift) & mask)
+	}
+	return x
+}
+
+// CloneMT19937 rebuilds a generator from at least 624 consecutive outputs of
+// another one. The returned generator continues where outputs end, so its
+// next output is the original's next output.
+//
+// The outputs don't need to start right after a twist: every word of state
+// is x[k+624] = x[k+397] ^ twist(x[k], x[k+1]) no matter where the twists
+// fall, so any 624 consecutive untempered outputs are a valid state. Use
+// CloneMT19937At to also recover the original's internal layout.
+func CloneMT19937(outputs []uint32) (*MT19937, error) {
+	if len(outputs) < mtN {
+		return nil, fmt.Errorf("need at least %d outputs, got %d", mtN, len(outputs))
+	}
+
+	m := new(MT19937)
+	for i, out := range outputs[:mtN] {
+		m.mt[i] = Untemper(out)
+	}
+	m.index = mtN
+	m.seeded = true
+
+	// Walk the clone past the remaining outputs, checking that it agrees
+	// with the original on each of them
+	for i, out := range outputs[mtN:] {
+		if m.Uint32() != out {
+			return nil, fmt.Errorf("output %d does not match the cloned state", mtN+i)
+		}
+	}
+	return m, nil
+}
+
+// CloneMT19937At is like CloneMT19937, but also rebuilds the original's
+// exact internal state. index is the original's position in its current
+// block of state after producing the last of outputs, i.e. outputs since
+// its last twist, in the range [1, 624].
+func CloneMT19937At(outputs []uint32, index int) (*MT19937, error) {
+	if index < 1 || index > mtN {
+		return nil, fmt.Errorf("index %d out of range [1, %d]", index, mtN)
+	}
+
+	// Check that all the outputs come from one generator
+	if _, err := CloneMT19937(outputs); err != nil {
+		return nil, err
+	}
+
+	// The last 624 outputs untempered are a window of state, and twisting
+	// it gives the 624 words that follow
+	next := new(MT19937)
+	for i, out := range outputs[len(outputs)-mtN:] {
+		next.mt[i] = Untemper(out)
+	}
+	next.twist()
+
+	// The last index outputs came from the start of the original's current
+	// block. The rest of the block is the words that follow them.
+	m := new(MT19937)
+	for i, out := range outputs[len(outputs)-index:] {
+		m.mt[i] = Untemper(out)
+	}
+	copy(m.mt[index:], next.mt[:mtN-index])
+	m.index = index
+	m.seeded = true
+	return m, nil
+}
+
+// firstOutput returns the first output of a generator seeded with seed. It
+// only computes the three words of state the first output depends on, which
+// makes it much cheaper than seeding and twisting a full generator.
+func firstOutput(seed uint32) uint32 {
+	mt0 := seed
+	x := seed
+	var mt1 uint32
+	for i := uint32(1); i <= mtM; i++ {
+		x = mtInitMult*(x^(x>>30)) + i
+		if i == 1 {
+			mt1 = x
 		}
-		index = 0
 	}
 
-	extractNumber := func() (int, error) {
-		if index >= n {
-			if index > n {
-				return 0, errors.New("generator never seeded")
+	y := (mt0 & mtUpperMask) | (mt1 & mtLowerMask)
+	yA := y >> 1
+	if y&1 != 0 {
+		yA ^= mtMatrixA
+	}
+	return temper(x ^ yA)
+}
+
+// crackSeeds returns every seed in [start, end) whose generator's first
+// output is output, in ascending order. The range is split across all CPUs.
+func crackSeeds(output uint32, start, end uint64) []uint32 {
+	if end <= start {
+		return nil
+	}
+
+	workers := uint64(runtime.NumCPU())
+	if end-start < workers {
+		workers = 1
+	}
+	chunk := (end - start + workers - 1) / workers
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var seeds []uint32
+
+	for lo := start; lo < end; lo += chunk {
+		hi := lo + chunk
+		if hi > end {
+			hi = end
+		}
+
+		wg.Add(1)
+		go func(lo, hi uint64) {
+			defer wg.Done()
+			for seed := lo; seed < hi; seed++ {
+				if firstOutput(uint32(seed)) == output {
+					mu.Lock()
+					seeds = append(seeds, uint32(seed))
+					mu.Unlock()
+				}
 			}
-			twist()
+		}(lo, hi)
+	}
+	wg.Wait()
+
+	sort.Slice(seeds, func(i, j int) bool { return seeds[i] < seeds[j] })
+	return seeds
+}
+
+// CrackSeed16 returns every 16-bit seed whose generator's first output is
+// output
+func CrackSeed16(output uint32) []uint16 {
+	seeds := crackSeeds(output, 0, 1<<16)
+	seeds16 := make([]uint16, len(seeds))
+	for i, seed := range seeds {
+		seeds16[i] = uint16(seed)
+	}
+	return seeds16
+}
+
+// CrackSeed32 returns every 32-bit seed whose generator's first output is
+// output. It searches the whole seed space, which takes minutes even when
+// spread over all CPUs.
+func CrackSeed32(output uint32) []uint32 {
+	return crackSeeds(output, 0, 1<<32)
+}
+
+// CrackTimeSeed returns every seed whose generator's first output is output,
+// assuming the generator was seeded with a Unix timestamp (in seconds) from
+// the window before now
+func CrackTimeSeed(output uint32, window time.Duration, now time.Time) []uint32 {
+	start, end := timeSeeds(window, now)
+	return crackSeeds(output, start, end)
+}
+
+// timeSeeds returns the range [start, end) of Unix timestamps, in seconds,
+// from the window before now that fit in a 32-bit seed
+func timeSeeds(window time.Duration, now time.Time) (uint64, uint64) {
+	end := now.Unix() + 1
+	start := end - int64(window/time.Second) - 1
+	if start < 0 {
+		start = 0
+	}
+	if end > 1<<32 {
+		end = 1 << 32
+	}
+	if end < start {
+		end = start
+	}
+	return uint64(start), uint64(end)
+}
+
+// RecoverMTStreamKey recovers the key of an MTStream ciphertext whose
+// plaintext ends with known, by trying every 16-bit key
+func RecoverMTStreamKey(ciphertext, known []byte) (uint16, error) {
+	if len(known) == 0 {
+		return 0, errors.New("no known plaintext")
+	}
+	if len(known) > len(ciphertext) {
+		return 0, errors.New("known plaintext longer than ciphertext")
+	}
+
+	offset := len(ciphertext) - len(known)
+	plaintext := make([]byte, len(ciphertext))
+
+	for key := 0; key < 1<<16; key++ {
+		MTStream(uint16(key)).XORKeyStream(plaintext, ciphertext)
+		if stdBytes.Equal(plaintext[offset:], known) {
+			return uint16(key), nil
+		}
+	}
+	return 0, errors.New("key not found")
+}
+
+// IsMTTimeToken reports whether token is the start of the output of an
+// MT19937 generator seeded with a Unix timestamp from the window before now
+func IsMTTimeToken(token []byte, window time.Duration, now time.Time) bool {
+	if len(token) == 0 {
+		return false
+	}
+
+	// Only check seeds whose first output matches the token's first word
+	var seeds []uint32
+	if len(token) >= 4 {
+		seeds = CrackTimeSeed(binary.LittleEndian.Uint32(token), window, now)
+	} else {
+		start, end := timeSeeds(window, now)
+		for seed := start; seed < end; seed++ {
+			seeds = append(seeds, uint32(seed))
+		}
+	}
+
+	b := make([]byte, len(token))
+	for _, seed := range seeds {
+		NewMT19937(seed).Read(b)
+		if stdBytes.Equal(b, token) {
+			return true
 		}
+	}
+	return false
+}
 
-		y := mt[index]
-		y = int(uint(y) ^ (uint(y)>>u)&d)
-		y = int(uint(y) ^ (uint(y)<<s)&b)
-		y = int(uint(y) ^ (uint(y)<<t)&c)
-		y = int(uint(y) ^ (uint(y) >> uint(1)))
+// ForgeCTR returns a copy of a CTR ciphertext whose plaintext at offset is
+// desired instead of knownPlaintext. Flipping a ciphertext bit flips the
+// same plaintext bit, so no other byte changes.
+func ForgeCTR(ciphertext, knownPlaintext []byte, offset int, desired []byte) ([]byte, error) {
+	if len(knownPlaintext) != len(desired) {
+		return nil, errors.New("length mismatch")
+	}
+	if offset < 0 || offset+len(desired) > len(ciphertext) {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	forged := make([]byte, len(ciphertext))
+	copy(forged, ciphertext)
+	for i := range desired {
+		forged[offset+i] ^= knownPlaintext[i] ^ desired[i]
+	}
+	return forged, nil
+}
+
+// ForgeCBC returns a copy of a CBC ciphertext (without its IV) whose
+// plaintext at offset is desired instead of knownPlaintext. The bytes are
+// flipped in the previous ciphertext block, which scrambles that block's
+// plaintext, so desired has to fit in one block after the first.
+func ForgeCBC(ciphertext []byte, blockSize int, knownPlaintext []byte, offset int, desired []byte) ([]byte, error) {
+	if blockSize < 1 || len(ciphertext)%blockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of block size %d", blockSize)
+	}
+	if len(knownPlaintext) != len(desired) {
+		return nil, errors.New("length mismatch")
+	}
+	if len(desired) == 0 {
+		return nil, errors.New("empty plaintext")
+	}
+	if offset < blockSize || offset+len(desired) > len(ciphertext) {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	if offset/blockSize != (offset+len(desired)-1)/blockSize {
+		return nil, errors.New("desired plaintext spans more than one block")
+	}
+
+	forged := make([]byte, len(ciphertext))
+	copy(forged, ciphertext)
+	for i := range desired {
+		forged[offset-blockSize+i] ^= knownPlaintext[i] ^ desired[i]
+	}
+	return forged, nil
+}
+
+// RecoverKeyIV recovers the key of a ciphertext encrypted with
+// CbcEncryptKeyIV, given a receiver like ReceiveKeyIV that leaks the
+// plaintext of messages it rejects. The receiver gets C1 || 0 || C1, which
+// decrypts to P1 || garbage || P1 ^ key.
+func RecoverKeyIV(ciphertext []byte, receiver func(ciphertext []byte) error) ([]byte, error) {
+	size := aes.BlockSize
+	if len(ciphertext) < 3*size {
+		return nil, errors.New("ciphertext must be at least 3 blocks")
+	}
+
+	attack := make([]byte, 3*size)
+	copy(attack, ciphertext[:size])
+	copy(attack[2*size:], ciphertext[:size])
+
+	err := receiver(attack)
+	if err == nil {
+		return nil, errors.New("receiver accepted the message")
+	}
+	var asciiErr *HighASCIIError
+	if !errors.As(err, &asciiErr) {
+		return nil, err
+	}
 
-		index++
-		return y & lowerMask, nil
+	plaintext := asciiErr.Plaintext
+	if len(plaintext) < 3*size {
+		return nil, errors.New("receiver leaked too little plaintext")
 	}
+	return bytes.Xor(plaintext[:size], plaintext[2*size:3*size])
+}
 
-	init(seed)
-	num, err := extractNumber()
+// EditOracle replaces the plaintext at offset in a CTR ciphertext with
+// newtext and returns the new ciphertext, like Edit with a secret key
+type EditOracle func(ciphertext []byte, offset int, newtext []byte) ([]byte, error)
+
+// BreakRandomAccessCTR recovers the plaintext of a CTR ciphertext through an
+// edit oracle. Writing the ciphertext over itself XORs the keystream out of
+// it, which leaves the plaintext.
+func BreakRandomAccessCTR(ciphertext []byte, oracle EditOracle) ([]byte, error) {
+	plaintext, err := oracle(ciphertext, 0, ciphertext)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if len(plaintext) != len(ciphertext) {
+		return nil, errors.New("oracle changed the length of the ciphertext")
 	}
-	return num, nil
+	return plaintext, nil
 }
@@ -1,7 +1,10 @@
 package crypto
 
 import (
+	"encoding/binary"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/taravancil/cryptopals/bytes"
 )
@@ -53,3 +56,455 @@ func TestFindKeysizes(t *testing.T) {
 		t.Error("guessed wrong key length")
 	}
 }
+
+func TestBreakSingleByteXor(t *testing.T) {
+	plaintext := []byte("Cooking MC's like a pound of bacon")
+	ciphertext, _ := bytes.XorRepeatingKey(plaintext, []byte{'X'})
+
+	candidates, err := BreakSingleByteXor(ciphertext, EnglishScorer, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Key != 'X' || string(candidates[0].Plaintext) != string(plaintext) {
+		t.Errorf("expected key 'X', got %q with %q", candidates[0].Key, candidates[0].Plaintext)
+	}
+	if candidates[0].Score < candidates[1].Score || candidates[1].Score < candidates[2].Score {
+		t.Error("candidates should be ordered by score")
+	}
+
+	// A scorer that only wants NUL bytes picks the most common byte
+	zeros := func(p []byte) float64 {
+		n := 0
+		for _, b := range p {
+			if b == 0 {
+				n++
+			}
+		}
+		return float64(n)
+	}
+	candidates, _ = BreakSingleByteXor([]byte{7, 3, 7, 1}, zeros, 1)
+	if candidates[0].Key != 7 {
+		t.Errorf("expected key 7, got %d", candidates[0].Key)
+	}
+
+	if _, err := BreakSingleByteXor(nil, EnglishScorer, 1); err == nil {
+		t.Error("should fail given an empty ciphertext")
+	}
+	if _, err := BreakSingleByteXor(ciphertext, EnglishScorer, 0); err == nil {
+		t.Error("should fail given k < 1")
+	}
+}
+
+func TestMT19937(t *testing.T) {
+	// First outputs of mt19937ar.c's genrand_int32 for init_genrand(5489)
+	expected := []uint32{3499211612, 581869302, 3890346734, 3586334585, 545404204}
+
+	m := NewMT19937(5489)
+	for i, want := range expected {
+		if got := m.Uint32(); got != want {
+			t.Errorf("output %d: expected %d, got %d", i, want, got)
+		}
+	}
+
+	// The 10000th output is the value C++ requires of std::mt19937
+	m.Seed(5489)
+	var n uint32
+	for i := 0; i < 10000; i++ {
+		n = m.Uint32()
+	}
+	if n != 4123659995 {
+		t.Errorf("expected 10000th output 4123659995, got %d", n)
+	}
+
+	// An unseeded generator uses the default seed
+	var zero MT19937
+	if got := zero.Uint32(); got != expected[0] {
+		t.Errorf("unseeded generator: expected %d, got %d", expected[0], got)
+	}
+}
+
+func TestMT19937SeedArray(t *testing.T) {
+	// First outputs from mt19937ar.out
+	expected := []uint32{1067595299, 955945823, 477289528, 4107218783, 4228976476}
+
+	var m MT19937
+	m.SeedArray([]uint32{0x123, 0x234, 0x345, 0x456})
+	for i, want := range expected {
+		if got := m.Uint32(); got != want {
+			t.Errorf("output %d: expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestMT19937Read(t *testing.T) {
+	m := NewMT19937(5489)
+	words := make([]uint32, 3)
+	for i := range words {
+		words[i] = m.Uint32()
+	}
+
+	// Odd-sized reads should still produce one continuous stream
+	m.Seed(5489)
+	b := make([]byte, 12)
+	m.Read(b[:3])
+	m.Read(b[3:7])
+	m.Read(b[7:])
+	for i, w := range words {
+		if got := binary.LittleEndian.Uint32(b[i*4:]); got != w {
+			t.Errorf("word %d: expected %d, got %d", i, w, got)
+		}
+	}
+
+	m.Seed(5489)
+	if got := m.Uint64(); got != uint64(words[0])<<32|uint64(words[1]) {
+		t.Errorf("unexpected Uint64 %d", got)
+	}
+
+	r := rand.New(NewMT19937(1).Source())
+	r.Seed(5489)
+	if got := r.Uint64(); got != uint64(words[0])<<32|uint64(words[1]) {
+		t.Errorf("unexpected rand.Source64 output %d", got)
+	}
+}
+
+func TestUntemper(t *testing.T) {
+	for _, y := range []uint32{0, 1, 0xffffffff, 0x80000000, 0xdeadbeef, 3499211612} {
+		if got := Untemper(temper(y)); got != y {
+			t.Errorf("Untemper(temper(%#x)) = %#x", y, got)
+		}
+	}
+}
+
+func TestCloneMT19937(t *testing.T) {
+	for _, skip := range []int{0, 1, 300, 624, 1000} {
+		victim := NewMT19937(0xc0ffee)
+		for i := 0; i < skip; i++ {
+			victim.Uint32()
+		}
+
+		outputs := make([]uint32, 624+skip%7)
+		for i := range outputs {
+			outputs[i] = victim.Uint32()
+		}
+
+		clone, err := CloneMT19937(outputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2000; i++ {
+			if clone.Uint32() != victim.Uint32() {
+				t.Fatalf("skip %d: clone diverged at output %d", skip, i)
+			}
+		}
+	}
+
+	_, err := CloneMT19937(make([]uint32, 623))
+	if err == nil {
+		t.Error("should fail given fewer than 624 outputs")
+	}
+
+	// Outputs that are not consecutive can't be cloned
+	victim := NewMT19937(1)
+	outputs := make([]uint32, 630)
+	for i := range outputs {
+		outputs[i] = victim.Uint32()
+	}
+	outputs[627]++
+	_, err = CloneMT19937(outputs)
+	if err == nil {
+		t.Error("should fail given outputs that don't match a single state")
+	}
+}
+
+func TestCloneMT19937At(t *testing.T) {
+	for _, n := range []int{624, 630, 1300} {
+		for _, skip := range []int{0, 1, 100, 623, 700, 1000} {
+			victim := NewMT19937(42)
+			for i := 0; i < skip; i++ {
+				victim.Uint32()
+			}
+
+			outputs := make([]uint32, n)
+			for i := range outputs {
+				outputs[i] = victim.Uint32()
+			}
+
+			clone, err := CloneMT19937At(outputs, victim.index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clone.mt != victim.mt || clone.index != victim.index {
+				t.Errorf("%d outputs, skip %d: cloned state differs from the original", n, skip)
+			}
+			if clone.Uint32() != victim.Uint32() {
+				t.Errorf("%d outputs, skip %d: clone is out of sync", n, skip)
+			}
+		}
+	}
+
+	_, err := CloneMT19937At(make([]uint32, 624), 0)
+	if err == nil {
+		t.Error("should fail given an index out of range")
+	}
+}
+
+func TestFirstOutput(t *testing.T) {
+	for _, seed := range []uint32{0, 1, 5489, 0xffffffff} {
+		if got, want := firstOutput(seed), NewMT19937(seed).Uint32(); got != want {
+			t.Errorf("seed %d: expected %d, got %d", seed, want, got)
+		}
+	}
+}
+
+func TestCrackSeed16(t *testing.T) {
+	seed := uint16(48879)
+	output := NewMT19937(uint32(seed)).Uint32()
+
+	seeds := CrackSeed16(output)
+	found := false
+	for _, s := range seeds {
+		if s == seed {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected seed %d among candidates %v", seed, seeds)
+	}
+}
+
+func TestCrackTimeSeed(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	seeded := now.Add(-17 * time.Minute)
+	output := NewMT19937(uint32(seeded.Unix())).Uint32()
+
+	seeds := CrackTimeSeed(output, time.Hour, now)
+	if len(seeds) != 1 || seeds[0] != uint32(seeded.Unix()) {
+		t.Errorf("expected [%d], got %v", seeded.Unix(), seeds)
+	}
+
+	// The seed is outside the window
+	seeds = CrackTimeSeed(output, 10*time.Minute, now)
+	if len(seeds) != 0 {
+		t.Errorf("expected no candidates, got %v", seeds)
+	}
+}
+
+func TestRecoverMTStreamKey(t *testing.T) {
+	key := uint16(0xbeef)
+	known := []byte("AAAAAAAAAAAAAA")
+	plaintext := append([]byte("random prefix"), known...)
+	ciphertext := make([]byte, len(plaintext))
+	MTStream(key).XORKeyStream(ciphertext, plaintext)
+
+	recovered, err := RecoverMTStreamKey(ciphertext, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != key {
+		t.Errorf("expected key %d, got %d", key, recovered)
+	}
+
+	_, err = RecoverMTStreamKey(ciphertext[:4], known)
+	if err == nil {
+		t.Error("should fail if known plaintext is longer than the ciphertext")
+	}
+}
+
+func TestIsMTTimeToken(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	token := make([]byte, 16)
+	NewMT19937(uint32(now.Add(-5 * time.Minute).Unix())).Read(token)
+
+	if !IsMTTimeToken(token, time.Hour, now) {
+		t.Error("expected a time-seeded token to be detected")
+	}
+	if !IsMTTimeToken(token[:3], time.Hour, now) {
+		t.Error("expected a short time-seeded token to be detected")
+	}
+
+	random, _ := bytes.Random(16)
+	if IsMTTimeToken(random, time.Hour, now) {
+		t.Error("a random token should not be detected")
+	}
+}
+
+func TestIsMTTimeTokenShort(t *testing.T) {
+	// Tokens shorter than a word search the same seeds as longer ones, even
+	// at the edges of a window that isn't a whole number of seconds
+	now := time.Unix(1500000000, 1e8)
+	window := 1500 * time.Millisecond
+	for seed := now.Unix() - 3; seed <= now.Unix()+1; seed++ {
+		token := make([]byte, 8)
+		NewMT19937(uint32(seed)).Read(token)
+		long, short := IsMTTimeToken(token, window, now), IsMTTimeToken(token[:3], window, now)
+		if long != short {
+			t.Errorf("seed %d: %d-byte token gives %v, 3-byte token gives %v", seed, len(token), long, short)
+		}
+	}
+}
+
+func TestBreakFixedNonceCTR(t *testing.T) {
+	plaintexts := []string{
+		"Like your mother and your father too.",
+		"All grown up but they're just like you.",
+		"Put your good face on, not foolin' no one.",
+		"You're a jackrabbit underneath.",
+		"And you're going to do it all anew.",
+		"Better run for the hills.",
+		"This is an English language sentence.",
+		"Cooking MC's like a pound of bacon",
+	}
+	key := []byte("YELLOW SUBMARINE")
+
+	ciphertexts := make([][]byte, len(plaintexts))
+	for i, p := range plaintexts {
+		stream, _ := Ctr(0, key)
+		ciphertexts[i] = make([]byte, len(p))
+		stream.XORKeyStream(ciphertexts[i], []byte(p))
+	}
+
+	broken, err := BreakFixedNonceCTR(ciphertexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broken.Keystream) != 42 || len(broken.Confidence) != 42 {
+		t.Fatalf("expected 42 bytes of keystream, got %d", len(broken.Keystream))
+	}
+
+	// Bytes the model is confident in should be right
+	for i, c := range broken.Confidence[:len(plaintexts[0])] {
+		if c > .99 && broken.Plaintexts[0][i] != plaintexts[0][i] {
+			t.Errorf("wrong plaintext byte at offset %d: %q", i, broken.Plaintexts[0][i])
+		}
+	}
+
+	// Only one ciphertext is long enough to give the last byte, so a hint
+	// has to supply it
+	hints := HintString(2, 40, "e.")
+	broken, err = BreakFixedNonceCTR(ciphertexts, hints...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(broken.Plaintexts[2][40:]) != "e." || broken.Confidence[41] != 1 {
+		t.Errorf("hints were not applied, got %q", broken.Plaintexts[2][40:])
+	}
+
+	// Hints about the same offset in different lines have to agree
+	agree := append(HintString(0, 0, "Li"), HintString(1, 0, "Al")...)
+	if _, err := BreakFixedNonceCTR(ciphertexts, agree...); err != nil {
+		t.Errorf("expected hints that agree to be accepted, got %v", err)
+	}
+	disagree := append(HintString(0, 0, "Li"), HintString(1, 0, "Ax")...)
+	if _, err := BreakFixedNonceCTR(ciphertexts, disagree...); err == nil {
+		t.Error("should fail given hints that disagree")
+	}
+
+	_, err = BreakFixedNonceCTR(ciphertexts, CtrHint{Ciphertext: 5, Offset: 30})
+	if err == nil {
+		t.Error("should fail given a hint past the end of a ciphertext")
+	}
+	_, err = BreakFixedNonceCTR(nil)
+	if err == nil {
+		t.Error("should fail given no ciphertexts")
+	}
+}
+
+func TestBreakRandomAccessCTR(t *testing.T) {
+	key := NewAesKey()
+	plaintext := []byte("All grown up but they're just like you.")
+	ciphertext := make([]byte, len(plaintext))
+	stream, _ := Ctr(0, key)
+	stream.XORKeyStream(ciphertext, plaintext)
+
+	oracle := func(ciphertext []byte, offset int, newtext []byte) ([]byte, error) {
+		return Edit(ciphertext, key, offset, newtext)
+	}
+	recovered, err := BreakRandomAccessCTR(ciphertext, oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recovered) != string(plaintext) {
+		t.Errorf("expected %s, got %s", plaintext, recovered)
+	}
+}
+
+func TestForgeCTR(t *testing.T) {
+	key := NewAesKey()
+	plaintext := []byte("comment1=cooking%20MCs;userdata=AAAAAAAAAAAA;comment2=")
+	ciphertext := make([]byte, len(plaintext))
+	stream, _ := Ctr(0, key)
+	stream.XORKeyStream(ciphertext, plaintext)
+
+	forged, err := ForgeCTR(ciphertext, []byte("AAAAAAAAAAAA"), 32, []byte(";admin=true;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := make([]byte, len(forged))
+	stream, _ = Ctr(0, key)
+	stream.XORKeyStream(decrypted, forged)
+
+	expected := "comment1=cooking%20MCs;userdata=;admin=true;;comment2="
+	if string(decrypted) != expected {
+		t.Errorf("expected %s, got %s", expected, decrypted)
+	}
+
+	if _, err := ForgeCTR(ciphertext, []byte("AA"), len(ciphertext)-1, []byte("BB")); err == nil {
+		t.Error("should fail if the forged bytes run past the end")
+	}
+	if _, err := ForgeCTR(ciphertext, []byte("AA"), 0, []byte("B")); err == nil {
+		t.Error("should fail on length mismatch")
+	}
+}
+
+func TestForgeCBC(t *testing.T) {
+	key := NewAesKey()
+	iv, _ := bytes.Random(16)
+	plaintext := []byte("comment1=cooking%20MCs;userdata=AAAAAAAAAAAA;comment2=")
+	ciphertext, _ := CbcEncrypt(plaintext, key, iv)
+
+	forged, err := ForgeCBC(ciphertext, 16, []byte("AAAAAAAAAAAA"), 32, []byte(";admin=true;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, _ := CbcDecrypt(forged, key, iv)
+
+	if string(decrypted[:16]) != string(plaintext[:16]) {
+		t.Error("blocks before the flipped block should be untouched")
+	}
+	if string(decrypted[32:44]) != ";admin=true;" {
+		t.Errorf("expected ;admin=true;, got %s", decrypted[32:44])
+	}
+	if string(decrypted[44:len(plaintext)]) != string(plaintext[44:]) {
+		t.Error("blocks after the target block should be untouched")
+	}
+
+	if _, err := ForgeCBC(ciphertext, 16, []byte("AAAA"), 30, []byte("BBBB")); err == nil {
+		t.Error("should fail if the forged bytes span two blocks")
+	}
+	if _, err := ForgeCBC(ciphertext, 16, []byte("AAAA"), 4, []byte("BBBB")); err == nil {
+		t.Error("should fail if the forged bytes are in the first block")
+	}
+}
+
+func TestRecoverKeyIV(t *testing.T) {
+	key := NewAesKey()
+	ciphertext, _ := CbcEncryptKeyIV([]byte("comment1=cooking%20MCs;userdata=;comment2=%20like%20a%20pound%20of%20bacon"), key)
+
+	receiver := func(ciphertext []byte) error {
+		return ReceiveKeyIV(ciphertext, key)
+	}
+	recovered, err := RecoverKeyIV(ciphertext, receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recovered) != string(key) {
+		t.Errorf("expected key %x, got %x", key, recovered)
+	}
+
+	if _, err := RecoverKeyIV(ciphertext[:32], receiver); err == nil {
+		t.Error("should fail given fewer than 3 blocks")
+	}
+}
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/taravancil/cryptopals/md4"
+	"github.com/taravancil/cryptopals/sha1"
+)
+
+// MACVerifier signs messages and checks their MACs under a key it keeps
+// to itself
+type MACVerifier interface {
+	Sign(message []byte) []byte
+	Verify(message, mac []byte) bool
+}
+
+// SecretPrefixMAC is the broken MAC H(key || message). hash is any hash
+// constructor, e.g. sha1.New, md4.New or crypto/sha256.New.
+type SecretPrefixMAC struct {
+	hash func() hash.Hash
+	key  []byte
+}
+
+// NewSecretPrefixMAC returns a SecretPrefixMAC keyed with key
+func NewSecretPrefixMAC(hash func() hash.Hash, key []byte) *SecretPrefixMAC {
+	return &SecretPrefixMAC{hash, append([]byte{}, key...)}
+}
+
+// Sign returns H(key || message)
+func (m *SecretPrefixMAC) Sign(message []byte) []byte {
+	h := m.hash()
+	h.Write(m.key)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// Verify reports whether mac is the MAC of message
+func (m *SecretPrefixMAC) Verify(message, mac []byte) bool {
+	return hmac.Equal(m.Sign(message), mac)
+}
+
+// HMAC is HMAC over any hash constructor, which isn't vulnerable to length
+// extension
+type HMAC struct {
+	hash func() hash.Hash
+	key  []byte
+}
+
+// NewHMAC returns an HMAC keyed with key
+func NewHMAC(hash func() hash.Hash, key []byte) *HMAC {
+	return &HMAC{hash, append([]byte{}, key...)}
+}
+
+// Sign returns the HMAC of message
+func (m *HMAC) Sign(message []byte) []byte {
+	h := hmac.New(m.hash, m.key)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// Verify reports whether mac is the HMAC of message
+func (m *HMAC) Verify(message, mac []byte) bool {
+	return hmac.Equal(m.Sign(message), mac)
+}
+
+// Extension is a length extension forgery for one guess at the secret's
+// length
+type Extension struct {
+	SecretLen int
+
+	// Suffix is what to append to the original message: the glue padding
+	// followed by the attacker's suffix
+	Suffix []byte
+
+	// MAC is the forged MAC of the extended message
+	MAC []byte
+}
+
+// LengthExtend forges MACs of the form H(secret || message) for
+// message || glue || suffix, given the MAC of a message of origLen bytes.
+// resume carries a hash on from a digest of length bytes and padding is its
+// padding for a message length, e.g. sha1.NewFromState and sha1.Padding.
+// The secret's length is unknown, so it returns one Extension per guess
+// from 0 to maxSecretLen bytes.
+func LengthExtend(resume func(sum []byte, length uint64) (hash.Hash, error), padding func(messageLen uint64) []byte, mac []byte, origLen, maxSecretLen int, suffix []byte) ([]Extension, error) {
+	if origLen < 0 {
+		return nil, fmt.Errorf("invalid message length %d", origLen)
+	}
+	if maxSecretLen < 0 {
+		return nil, fmt.Errorf("invalid maximum secret length %d", maxSecretLen)
+	}
+
+	extensions := make([]Extension, 0, maxSecretLen+1)
+	for secretLen := 0; secretLen <= maxSecretLen; secretLen++ {
+		total := uint64(secretLen + origLen)
+		glue := padding(total)
+		h, err := resume(mac, total+uint64(len(glue)))
+		if err != nil {
+			return nil, err
+		}
+		h.Write(suffix)
+		extensions = append(extensions, Extension{
+			SecretLen: secretLen,
+			Suffix:    append(glue, suffix...),
+			MAC:       h.Sum(nil),
+		})
+	}
+	return extensions, nil
+}
+
+// ForgeSHA1PrefixMAC appends glue padding and suffix to a message signed
+// with a SHA-1 SecretPrefixMAC, and forges the MAC of the result without
+// the key. verify is asked about each guess at the key's length, up to
+// maxKeyLen bytes.
+func ForgeSHA1PrefixMAC(message, mac, suffix []byte, maxKeyLen int, verify func(message, mac []byte) bool) ([]byte, []byte, error) {
+	extensions, err := LengthExtend(sha1.NewFromState, sha1.Padding, mac, len(message), maxKeyLen, suffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return forgePrefixMAC(message, extensions, verify)
+}
+
+// ForgeMD4PrefixMAC is like ForgeSHA1PrefixMAC for an MD4 SecretPrefixMAC
+func ForgeMD4PrefixMAC(message, mac, suffix []byte, maxKeyLen int, verify func(message, mac []byte) bool) ([]byte, []byte, error) {
+	extensions, err := LengthExtend(md4.NewFromState, md4.Padding, mac, len(message), maxKeyLen, suffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return forgePrefixMAC(message, extensions, verify)
+}
+
+func forgePrefixMAC(message []byte, extensions []Extension, verify func(message, mac []byte) bool) ([]byte, []byte, error) {
+	for _, e := range extensions {
+		forged := stdBytes.Join([][]byte{message, e.Suffix}, nil)
+		if verify(forged, e.MAC) {
+			return forged, e.MAC, nil
+		}
+	}
+	return nil, nil, errors.New("no forgery verified; is the key longer than the guesses?")
+}
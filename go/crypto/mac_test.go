@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"crypto/sha256"
+	"hash"
+	"testing"
+
+	"github.com/taravancil/cryptopals/bytes"
+	"github.com/taravancil/cryptopals/md4"
+	"github.com/taravancil/cryptopals/sha1"
+)
+
+var macHashes = map[string]func() hash.Hash{
+	"SHA-1":   sha1.New,
+	"MD4":     md4.New,
+	"SHA-256": sha256.New,
+}
+
+func TestMACVerifiers(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	message := []byte("comment1=cooking%20MCs;userdata=foo")
+
+	for name, h := range macHashes {
+		for _, v := range []MACVerifier{NewSecretPrefixMAC(h, key), NewHMAC(h, key)} {
+			mac := v.Sign(message)
+			if !v.Verify(message, mac) {
+				t.Errorf("%s %T: expected the MAC to verify", name, v)
+			}
+
+			tampered := append([]byte{}, message...)
+			tampered[0] ^= 1
+			if v.Verify(tampered, mac) {
+				t.Errorf("%s %T: expected a tampered message to fail", name, v)
+			}
+			if v.Verify(message, mac[1:]) {
+				t.Errorf("%s %T: expected a truncated MAC to fail", name, v)
+			}
+		}
+	}
+
+	// Secret-prefix SHA-256 is just SHA-256 of the key and message
+	expected := sha256.Sum256(append(append([]byte{}, key...), message...))
+	if !stdBytes.Equal(NewSecretPrefixMAC(sha256.New, key).Sign(message), expected[:]) {
+		t.Error("secret-prefix MAC should hash the key then the message")
+	}
+}
+
+func TestForgePrefixMAC(t *testing.T) {
+	message := []byte("email=foo@bar.com&uid=1&role=user")
+	suffix := []byte(";admin=true")
+
+	forgers := map[string]func(message, mac, suffix []byte, maxKeyLen int, verify func(message, mac []byte) bool) ([]byte, []byte, error){
+		"SHA-1": ForgeSHA1PrefixMAC,
+		"MD4":   ForgeMD4PrefixMAC,
+	}
+	for name, forge := range forgers {
+		key, _ := bytes.Random(1 + r.Intn(32))
+		v := NewSecretPrefixMAC(macHashes[name], key)
+
+		forged, mac, err := forge(message, v.Sign(message), suffix, 32, v.Verify)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !v.Verify(forged, mac) {
+			t.Errorf("%s: forged MAC doesn't verify", name)
+		}
+		if !stdBytes.HasPrefix(forged, message) || !stdBytes.HasSuffix(forged, suffix) {
+			t.Errorf("%s: unexpected forged message %q", name, forged)
+		}
+
+		// Guessing short of the key's length finds nothing
+		if _, _, err := forge(message, v.Sign(message), suffix, len(key)-1, v.Verify); err == nil {
+			t.Errorf("%s: should fail when the key is longer than the guesses", name)
+		}
+
+		// HMAC doesn't fall for it
+		hm := NewHMAC(macHashes[name], key)
+		if _, _, err := forge(message, hm.Sign(message), suffix, 32, hm.Verify); err == nil {
+			t.Errorf("%s: should fail to forge an HMAC", name)
+		}
+	}
+}
+
+func TestLengthExtend(t *testing.T) {
+	secret := []byte("YELLOW SUBMARINE")
+	message := []byte("comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon")
+	suffix := []byte(";admin=true")
+	prefixed := append(append([]byte{}, secret...), message...)
+
+	hashes := map[string]struct {
+		sum     func([]byte) []byte
+		resume  func([]byte, uint64) (hash.Hash, error)
+		padding func(uint64) []byte
+	}{
+		"SHA-1": {sha1.Sum, sha1.NewFromState, sha1.Padding},
+		"MD4":   {md4.Sum, md4.NewFromState, md4.Padding},
+	}
+	for name, h := range hashes {
+		extensions, err := LengthExtend(h.resume, h.padding, h.sum(prefixed), len(message), 20, suffix)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(extensions) != 21 {
+			t.Fatalf("%s: expected 21 guesses, got %d", name, len(extensions))
+		}
+
+		valid := 0
+		for _, e := range extensions {
+			forged := append(append([]byte{}, prefixed...), e.Suffix...)
+			if stdBytes.Equal(h.sum(forged), e.MAC) {
+				valid++
+				if e.SecretLen != len(secret) {
+					t.Errorf("%s: forgery valid for the wrong secret length %d", name, e.SecretLen)
+				}
+				if !stdBytes.HasSuffix(e.Suffix, suffix) {
+					t.Errorf("%s: forged message should end with the suffix", name)
+				}
+			}
+		}
+		if valid != 1 {
+			t.Errorf("%s: expected exactly one valid forgery, got %d", name, valid)
+		}
+	}
+
+	if _, err := LengthExtend(sha1.NewFromState, sha1.Padding, sha1.Sum(prefixed), len(message), -1, suffix); err == nil {
+		t.Error("expected an error for a negative maximum secret length")
+	}
+}
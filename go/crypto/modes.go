@@ -0,0 +1,308 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// checkIV returns an error unless iv is one block of b long
+func checkIV(b cipher.Block, iv []byte) error {
+	if len(iv) != b.BlockSize() {
+		return fmt.Errorf("iv must be %d bytes", b.BlockSize())
+	}
+	return nil
+}
+
+type ofb struct {
+	b         cipher.Block
+	keystream []byte
+	used      int
+}
+
+// NewOFB returns a stream that encrypts or decrypts under OFB mode. The
+// keystream is the IV encrypted over and over, so it never depends on the
+// plaintext.
+func NewOFB(b cipher.Block, iv []byte) (cipher.Stream, error) {
+	if err := checkIV(b, iv); err != nil {
+		return nil, err
+	}
+	keystream := make([]byte, len(iv))
+	copy(keystream, iv)
+	return &ofb{b: b, keystream: keystream, used: len(keystream)}, nil
+}
+
+func (s *ofb) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	for i := range src {
+		if s.used == len(s.keystream) {
+			s.b.Encrypt(s.keystream, s.keystream)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.keystream[s.used]
+		s.used++
+	}
+}
+
+type cfb struct {
+	b         cipher.Block
+	register  []byte
+	keystream []byte
+	used      int
+	decrypt   bool
+}
+
+// NewCFBEncrypter returns a stream that encrypts under full-block CFB mode.
+// Each block of keystream is the encryption of the previous ciphertext
+// block.
+func NewCFBEncrypter(b cipher.Block, iv []byte) (cipher.Stream, error) {
+	return newCFB(b, iv, false)
+}
+
+// NewCFBDecrypter returns a stream that decrypts under full-block CFB mode
+func NewCFBDecrypter(b cipher.Block, iv []byte) (cipher.Stream, error) {
+	return newCFB(b, iv, true)
+}
+
+func newCFB(b cipher.Block, iv []byte, decrypt bool) (cipher.Stream, error) {
+	if err := checkIV(b, iv); err != nil {
+		return nil, err
+	}
+	register := make([]byte, len(iv))
+	copy(register, iv)
+	return &cfb{
+		b:         b,
+		register:  register,
+		keystream: make([]byte, len(iv)),
+		used:      len(iv),
+		decrypt:   decrypt,
+	}, nil
+}
+
+func (s *cfb) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	for i := range src {
+		if s.used == len(s.keystream) {
+			s.b.Encrypt(s.keystream, s.register)
+			s.used = 0
+		}
+
+		// The register collects the ciphertext to encrypt for the next
+		// block of keystream
+		c := src[i] ^ s.keystream[s.used]
+		if s.decrypt {
+			s.register[s.used] = src[i]
+		} else {
+			s.register[s.used] = c
+		}
+		dst[i] = c
+		s.used++
+	}
+}
+
+type cfb8 struct {
+	b         cipher.Block
+	register  []byte
+	keystream []byte
+	decrypt   bool
+}
+
+// NewCFB8Encrypter returns a stream that encrypts under 8-bit CFB mode,
+// which runs the block cipher once per byte and shifts each ciphertext byte
+// into the register
+func NewCFB8Encrypter(b cipher.Block, iv []byte) (cipher.Stream, error) {
+	return newCFB8(b, iv, false)
+}
+
+// NewCFB8Decrypter returns a stream that decrypts under 8-bit CFB mode
+func NewCFB8Decrypter(b cipher.Block, iv []byte) (cipher.Stream, error) {
+	return newCFB8(b, iv, true)
+}
+
+func newCFB8(b cipher.Block, iv []byte, decrypt bool) (cipher.Stream, error) {
+	if err := checkIV(b, iv); err != nil {
+		return nil, err
+	}
+	register := make([]byte, len(iv))
+	copy(register, iv)
+	return &cfb8{
+		b:         b,
+		register:  register,
+		keystream: make([]byte, len(iv)),
+		decrypt:   decrypt,
+	}, nil
+}
+
+func (s *cfb8) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	for i := range src {
+		s.b.Encrypt(s.keystream, s.register)
+		in := src[i]
+		out := in ^ s.keystream[0]
+
+		c := out
+		if s.decrypt {
+			c = in
+		}
+		copy(s.register, s.register[1:])
+		s.register[len(s.register)-1] = c
+		dst[i] = out
+	}
+}
+
+type pcbc struct {
+	b         cipher.Block
+	blockSize int
+	chain     []byte
+	decrypt   bool
+}
+
+// NewPCBCEncrypter returns a BlockMode that encrypts under PCBC mode, where
+// each plaintext block is XORed with both the previous plaintext and
+// ciphertext blocks before being encrypted
+func NewPCBCEncrypter(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newPCBC(b, iv, false)
+}
+
+// NewPCBCDecrypter returns a BlockMode that decrypts under PCBC mode
+func NewPCBCDecrypter(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newPCBC(b, iv, true)
+}
+
+func newPCBC(b cipher.Block, iv []byte, decrypt bool) (cipher.BlockMode, error) {
+	if err := checkIV(b, iv); err != nil {
+		return nil, err
+	}
+	chain := make([]byte, len(iv))
+	copy(chain, iv)
+	return &pcbc{b: b, blockSize: len(iv), chain: chain, decrypt: decrypt}, nil
+}
+
+func (m *pcbc) BlockSize() int {
+	return m.blockSize
+}
+
+func (m *pcbc) CryptBlocks(dst, src []byte) {
+	if len(src)%m.blockSize != 0 {
+		panic("input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+
+	in := make([]byte, m.blockSize)
+	for len(src) > 0 {
+		// Copy the input block in case dst and src overlap
+		copy(in, src[:m.blockSize])
+		out := dst[:m.blockSize]
+
+		if m.decrypt {
+			m.b.Decrypt(out, in)
+			for i := range out {
+				out[i] ^= m.chain[i]
+			}
+		} else {
+			for i := range out {
+				out[i] = in[i] ^ m.chain[i]
+			}
+			m.b.Encrypt(out, out)
+		}
+
+		// The next block is chained with plaintext XOR ciphertext
+		for i := range m.chain {
+			m.chain[i] = in[i] ^ out[i]
+		}
+
+		src = src[m.blockSize:]
+		dst = dst[m.blockSize:]
+	}
+}
+
+// xtsBlockSize is the only block size XTS is defined for
+const xtsBlockSize = 16
+
+type xts struct {
+	data    cipher.Block
+	tweak   []byte
+	decrypt bool
+}
+
+// NewXTSEncrypter returns a BlockMode that encrypts one data unit (e.g. a
+// disk sector) under XTS mode. data encrypts the blocks and tweak encrypts
+// the data unit number, the two being keyed independently. Consecutive
+// calls to CryptBlocks continue the same data unit. Data units that aren't
+// a whole number of blocks, which XTS handles with ciphertext stealing, are
+// not supported.
+func NewXTSEncrypter(data, tweak cipher.Block, unit uint64) (cipher.BlockMode, error) {
+	return newXTS(data, tweak, unit, false)
+}
+
+// NewXTSDecrypter returns a BlockMode that decrypts one data unit under XTS
+// mode
+func NewXTSDecrypter(data, tweak cipher.Block, unit uint64) (cipher.BlockMode, error) {
+	return newXTS(data, tweak, unit, true)
+}
+
+func newXTS(data, tweak cipher.Block, unit uint64, decrypt bool) (cipher.BlockMode, error) {
+	if data.BlockSize() != xtsBlockSize || tweak.BlockSize() != xtsBlockSize {
+		return nil, fmt.Errorf("XTS requires %d-byte blocks", xtsBlockSize)
+	}
+
+	// The data unit number is encoded little-endian
+	t := make([]byte, xtsBlockSize)
+	binary.LittleEndian.PutUint64(t, unit)
+	tweak.Encrypt(t, t)
+	return &xts{data: data, tweak: t, decrypt: decrypt}, nil
+}
+
+func (m *xts) BlockSize() int {
+	return xtsBlockSize
+}
+
+func (m *xts) CryptBlocks(dst, src []byte) {
+	if len(src)%xtsBlockSize != 0 {
+		panic("input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+
+	for len(src) > 0 {
+		out := dst[:xtsBlockSize]
+		for i := range out {
+			out[i] = src[i] ^ m.tweak[i]
+		}
+		if m.decrypt {
+			m.data.Decrypt(out, out)
+		} else {
+			m.data.Encrypt(out, out)
+		}
+		for i := range out {
+			out[i] ^= m.tweak[i]
+		}
+		mulAlpha(m.tweak)
+
+		src = src[xtsBlockSize:]
+		dst = dst[xtsBlockSize:]
+	}
+}
+
+// mulAlpha multiplies an XTS tweak by the primitive element α of
+// GF(2^128), with the tweak's bytes in little-endian order
+func mulAlpha(t []byte) {
+	var carry byte
+	for i := range t {
+		next := t[i] >> 7
+		t[i] = t[i]<<1 | carry
+		carry = next
+	}
+	if carry != 0 {
+		t[0] ^= 0x87
+	}
+}
@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+// NIST SP 800-38A test vectors for AES-128
+const (
+	nistKey       = "2b7e151628aed2a6abf7158809cf4f3c"
+	nistIV        = "000102030405060708090a0b0c0d0e0f"
+	nistPlaintext = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+)
+
+func nistBlock(t *testing.T) (cipher.Block, []byte, []byte) {
+	key, _ := hex.DecodeString(nistKey)
+	iv, _ := hex.DecodeString(nistIV)
+	plaintext, _ := hex.DecodeString(nistPlaintext)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block, iv, plaintext
+}
+
+// testStream encrypts plaintext in two pieces, checks the result against
+// expected, then decrypts it again
+func testStream(t *testing.T, name string, enc, dec cipher.Stream, plaintext []byte, expected string) {
+	ciphertext := make([]byte, len(plaintext))
+	enc.XORKeyStream(ciphertext[:5], plaintext[:5])
+	enc.XORKeyStream(ciphertext[5:], plaintext[5:])
+	if hex.EncodeToString(ciphertext) != expected {
+		t.Errorf("%s: expected %s, got %x", name, expected, ciphertext)
+	}
+
+	decrypted := make([]byte, len(ciphertext))
+	dec.XORKeyStream(decrypted[:13], ciphertext[:13])
+	dec.XORKeyStream(decrypted[13:], ciphertext[13:])
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("%s: decryption failed, got %x", name, decrypted)
+	}
+}
+
+func TestOFB(t *testing.T) {
+	block, iv, plaintext := nistBlock(t)
+
+	// F.4.1
+	enc, _ := NewOFB(block, iv)
+	dec, _ := NewOFB(block, iv)
+	testStream(t, "OFB", enc, dec, plaintext, "3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed8259740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e")
+
+	if _, err := NewOFB(block, iv[1:]); err == nil {
+		t.Error("should fail given an iv of the wrong size")
+	}
+}
+
+func TestCFB(t *testing.T) {
+	block, iv, plaintext := nistBlock(t)
+
+	// F.3.13
+	enc, _ := NewCFBEncrypter(block, iv)
+	dec, _ := NewCFBDecrypter(block, iv)
+	testStream(t, "CFB", enc, dec, plaintext, "3b3fd92eb72dad20333449f8e83cfb4ac8a64537a0b3a93fcde3cdad9f1ce58b26751f67a3cbb140b1808cf187a4f4dfc04b05357c5d1c0eeac4c66f9ff7f2e6")
+
+	// F.3.7
+	enc, _ = NewCFB8Encrypter(block, iv)
+	dec, _ = NewCFB8Decrypter(block, iv)
+	testStream(t, "CFB8", enc, dec, plaintext[:18], "3b79424c9c0dd436bace9e0ed4586a4f32b9")
+}
+
+func TestPCBC(t *testing.T) {
+	block, iv, plaintext := nistBlock(t)
+
+	enc, _ := NewPCBCEncrypter(block, iv)
+	ciphertext := make([]byte, len(plaintext))
+	enc.CryptBlocks(ciphertext, plaintext)
+
+	// The first block is the same as under CBC (F.2.1)
+	if hex.EncodeToString(ciphertext[:16]) != "7649abac8119b246cee98e9b12e9197d" {
+		t.Errorf("unexpected first block %x", ciphertext[:16])
+	}
+
+	// Later blocks are chained with the previous plaintext and ciphertext
+	expected := make([]byte, 16)
+	for i := range expected {
+		expected[i] = plaintext[16+i] ^ plaintext[i] ^ ciphertext[i]
+	}
+	block.Encrypt(expected, expected)
+	if hex.EncodeToString(ciphertext[16:32]) != hex.EncodeToString(expected) {
+		t.Errorf("expected second block %x, got %x", expected, ciphertext[16:32])
+	}
+
+	dec, _ := NewPCBCDecrypter(block, iv)
+	decrypted := make([]byte, len(ciphertext))
+	dec.CryptBlocks(decrypted[:32], ciphertext[:32])
+	dec.CryptBlocks(decrypted[32:], ciphertext[32:])
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("decryption failed, got %x", decrypted)
+	}
+
+	// A corrupted ciphertext block garbles every plaintext block after it
+	ciphertext[17] ^= 1
+	dec, _ = NewPCBCDecrypter(block, iv)
+	dec.CryptBlocks(decrypted, ciphertext)
+	if string(decrypted[48:]) == string(plaintext[48:]) {
+		t.Error("corruption should propagate to the last block")
+	}
+}
+
+func TestXTS(t *testing.T) {
+	// IEEE 1619-2007 vectors 1 and 2
+	vectors := []struct {
+		key1, key2 string
+		unit       uint64
+		plaintext  string
+		ciphertext string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"00000000000000000000000000000000",
+			0,
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+		},
+		{
+			"11111111111111111111111111111111",
+			"22222222222222222222222222222222",
+			0x3333333333,
+			"4444444444444444444444444444444444444444444444444444444444444444",
+			"c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+		},
+	}
+
+	for _, v := range vectors {
+		key1, _ := hex.DecodeString(v.key1)
+		key2, _ := hex.DecodeString(v.key2)
+		plaintext, _ := hex.DecodeString(v.plaintext)
+		data, _ := aes.NewCipher(key1)
+		tweak, _ := aes.NewCipher(key2)
+
+		enc, err := NewXTSEncrypter(data, tweak, v.unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext := make([]byte, len(plaintext))
+		enc.CryptBlocks(ciphertext, plaintext)
+		if hex.EncodeToString(ciphertext) != v.ciphertext {
+			t.Errorf("expected %s, got %x", v.ciphertext, ciphertext)
+		}
+
+		dec, _ := NewXTSDecrypter(data, tweak, v.unit)
+		dec.CryptBlocks(ciphertext, ciphertext)
+		if string(ciphertext) != string(plaintext) {
+			t.Errorf("decryption failed, got %x", ciphertext)
+		}
+	}
+
+	toy, _ := NewToyCipher([]byte("8bytekey"))
+	if _, err := NewXTSEncrypter(toy, toy, 0); err == nil {
+		t.Error("should fail given a cipher without 16-byte blocks")
+	}
+}
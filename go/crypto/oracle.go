@@ -0,0 +1,512 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/taravancil/cryptopals/blocks"
+	"github.com/taravancil/cryptopals/bytes"
+)
+
+// PaddingOracle reports whether a CBC ciphertext decrypts under iv to a
+// plaintext with valid PKCS#7 padding. Implementations can wrap anything
+// that leaks padding errors, like an HTTP service.
+type PaddingOracle interface {
+	ValidPadding(iv, ciphertext []byte) (bool, error)
+}
+
+// PaddingOracleFunc adapts a function to a PaddingOracle
+type PaddingOracleFunc func(iv, ciphertext []byte) (bool, error)
+
+func (f PaddingOracleFunc) ValidPadding(iv, ciphertext []byte) (bool, error) {
+	return f(iv, ciphertext)
+}
+
+type cbcPaddingOracle struct {
+	key []byte
+}
+
+// NewCbcPaddingOracle returns a PaddingOracle that decrypts with AES CBC
+// under key
+func NewCbcPaddingOracle(key []byte) PaddingOracle {
+	return &cbcPaddingOracle{key: key}
+}
+
+func (o *cbcPaddingOracle) ValidPadding(iv, ciphertext []byte) (bool, error) {
+	plaintext, err := CbcDecrypt(ciphertext, o.key, iv)
+	if err != nil {
+		return false, err
+	}
+
+	valid, _, _ := blocks.ValidPkcs7(plaintext)
+	return valid, nil
+}
+
+// PaddingOracleDecrypt decrypts a CBC ciphertext with a padding oracle and
+// returns the plaintext with its padding removed. The block size is taken
+// from the length of iv. Blocks are decrypted in parallel.
+func PaddingOracleDecrypt(oracle PaddingOracle, iv, ciphertext []byte) ([]byte, error) {
+	size := len(iv)
+	if size == 0 {
+		return nil, errors.New("empty iv")
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%size != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+
+	n := len(ciphertext) / size
+	plaintext := make([]byte, len(ciphertext))
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			prev := iv
+			if i > 0 {
+				prev = ciphertext[(i-1)*size : i*size]
+			}
+			intermediate, err := paddingOracleBlock(oracle, ciphertext[i*size:(i+1)*size])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for j := range intermediate {
+				plaintext[i*size+j] = intermediate[j] ^ prev[j]
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	valid, pad, err := blocks.ValidPkcs7Blocksize(plaintext, size)
+	if !valid {
+		return nil, err
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// PaddingOracleEncrypt forges a CBC ciphertext and IV that decrypt to
+// plaintext, padded with PKCS#7, under the oracle's key. It works backwards
+// from a random final block, using the oracle to learn what each block
+// decrypts to and choosing the block before it to turn that into plaintext.
+func PaddingOracleEncrypt(oracle PaddingOracle, plaintext []byte, blockSize int) (iv, ciphertext []byte, err error) {
+	padded, err := blocks.Pkcs7(plaintext, blockSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := len(padded) / blockSize
+	forged := make([]byte, len(padded)+blockSize)
+	last, err := bytes.Random(blockSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	copy(forged[n*blockSize:], last)
+
+	for i := n; i > 0; i-- {
+		intermediate, err := paddingOracleBlock(oracle, forged[i*blockSize:(i+1)*blockSize])
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range intermediate {
+			forged[(i-1)*blockSize+j] = intermediate[j] ^ padded[(i-1)*blockSize+j]
+		}
+	}
+	return forged[:blockSize], forged[blockSize:], nil
+}
+
+// paddingOracleBlock returns the intermediate state of a block, i.e. what it
+// decrypts to before being XORed with the previous block. It passes the
+// oracle a controlled IV, working from the last byte to the first.
+func paddingOracleBlock(oracle PaddingOracle, block []byte) ([]byte, error) {
+	size := len(block)
+	controlled := make([]byte, size)
+	intermediate := make([]byte, size)
+
+	for i := size - 1; i >= 0; i-- {
+		paddingByte := byte(size - i)
+
+		// Set the bytes after i so they decrypt to valid padding bytes
+		for j := i + 1; j < size; j++ {
+			controlled[j] = paddingByte ^ intermediate[j]
+		}
+
+		found := false
+		for b := 0; b < 256; b++ {
+			controlled[i] = byte(b)
+			valid, err := oracle.ValidPadding(controlled, block)
+			if err != nil {
+				return nil, err
+			}
+			if !valid {
+				continue
+			}
+
+			// For the last byte, valid padding might be \x02\x02 or
+			// longer rather than \x01. Changing the byte before it only
+			// keeps the padding valid if it is \x01.
+			if i == size-1 && size > 1 {
+				controlled[i-1] ^= 1
+				valid, err = oracle.ValidPadding(controlled, block)
+				controlled[i-1] ^= 1
+				if err != nil {
+					return nil, err
+				}
+				if !valid {
+					continue
+				}
+			}
+
+			intermediate[i] = paddingByte ^ byte(b)
+			found = true
+			break
+		}
+		if !found {
+			return nil, errors.New("oracle never reported valid padding")
+		}
+	}
+	return intermediate, nil
+}
+
+// EcbSuffixOracle encrypts attacker-controlled plaintext under ECB mode,
+// between an optional prefix and a secret suffix:
+// ECB(prefix || plaintext || suffix, key). The prefix may have a random
+// length on every call.
+type EcbSuffixOracle interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+}
+
+// EcbSuffixOracleFunc adapts a function to an EcbSuffixOracle
+type EcbSuffixOracleFunc func(plaintext []byte) ([]byte, error)
+
+func (f EcbSuffixOracleFunc) Encrypt(plaintext []byte) ([]byte, error) {
+	return f(plaintext)
+}
+
+const (
+	// The largest block size DetectEcbBlockSize looks for
+	maxBlockSize = 64
+
+	// How many times an aligned query is tried before giving up on
+	// getting the alignment it needs from a random prefix
+	maxAlignAttempts = 1000
+
+	markerByte = 'M'
+	fenceByte  = 'F'
+	fillByte   = 'A'
+	padByte    = 'P'
+)
+
+// DetectEcbBlockSize returns the block size of an ECB oracle by encrypting
+// a run of identical bytes and finding the smallest size at which it
+// encrypts to identical consecutive blocks
+func DetectEcbBlockSize(oracle EcbSuffixOracle) (int, error) {
+	ciphertext, err := oracle.Encrypt(repeatByte(markerByte, 4*maxBlockSize))
+	if err != nil {
+		return 0, err
+	}
+
+	// Smaller sizes are too likely to repeat by chance
+	for size := 4; size <= maxBlockSize; size++ {
+		if len(ciphertext)%size != 0 {
+			continue
+		}
+		for i := 0; i+2*size <= len(ciphertext); i += size {
+			if string(ciphertext[i:i+size]) == string(ciphertext[i+size:i+2*size]) {
+				return size, nil
+			}
+		}
+	}
+	return 0, errors.New("no repeated blocks, oracle is not using ECB")
+}
+
+// ecbAligner makes queries to an EcbSuffixOracle land on a block boundary
+// past the prefix. Each query is preceded by pad bytes, a marker block and
+// a fence block, and only counts once both show up as consecutive
+// ciphertext blocks. A prefix that happens to end in marker bytes can fake
+// the marker block, but then the fence block is off by as many bytes.
+type ecbAligner struct {
+	oracle        EcbSuffixOracle
+	size          int
+	marker, fence []byte
+	pad           int
+}
+
+func newEcbAligner(oracle EcbSuffixOracle, size int) (*ecbAligner, error) {
+	marker, err := encryptBlockOf(oracle, markerByte, size)
+	if err != nil {
+		return nil, err
+	}
+	fence, err := encryptBlockOf(oracle, fenceByte, size)
+	if err != nil {
+		return nil, err
+	}
+	return &ecbAligner{oracle: oracle, size: size, marker: marker, fence: fence}, nil
+}
+
+// encryptBlockOf returns the encryption of a block of b under an ECB oracle
+func encryptBlockOf(oracle EcbSuffixOracle, b byte, size int) ([]byte, error) {
+	// Enough bytes to fill three blocks wherever the prefix ends
+	ciphertext, err := oracle.Encrypt(repeatByte(b, 4*size-1))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+2*size <= len(ciphertext); i += size {
+		if string(ciphertext[i:i+size]) == string(ciphertext[i+size:i+2*size]) {
+			return ciphertext[i : i+size], nil
+		}
+	}
+	return nil, errors.New("no repeated blocks, oracle is not using ECB")
+}
+
+// query returns the encryption of plaintext || suffix, with the prefix cut
+// off
+func (a *ecbAligner) query(plaintext []byte) ([]byte, error) {
+	for attempt := 0; attempt < maxAlignAttempts; attempt++ {
+		// Retry with the pad length that last worked first
+		pad := (a.pad + attempt) % a.size
+
+		input := repeatByte(padByte, pad)
+		input = append(input, repeatByte(markerByte, a.size)...)
+		input = append(input, repeatByte(fenceByte, a.size)...)
+		input = append(input, plaintext...)
+
+		ciphertext, err := a.oracle.Encrypt(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i+2*a.size <= len(ciphertext); i += a.size {
+			if string(ciphertext[i:i+a.size]) == string(a.marker) &&
+				string(ciphertext[i+a.size:i+2*a.size]) == string(a.fence) {
+				a.pad = pad
+				return ciphertext[i+2*a.size:], nil
+			}
+		}
+	}
+	return nil, errors.New("could not align input to a block boundary")
+}
+
+// RecoverEcbSuffix recovers the secret suffix an EcbSuffixOracle appends to
+// its input, one byte at a time. The block size, prefix and suffix lengths
+// are all worked out from the oracle's output.
+func RecoverEcbSuffix(oracle EcbSuffixOracle) ([]byte, error) {
+	size, err := DetectEcbBlockSize(oracle)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newEcbAligner(oracle, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// The padded suffix grows by a block once the input plus suffix fills
+	// the last block
+	empty, err := a.query(nil)
+	if err != nil {
+		return nil, err
+	}
+	suffixLen := -1
+	for i := 1; i <= size; i++ {
+		ciphertext, err := a.query(repeatByte(fillByte, i))
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphertext) > len(empty) {
+			suffixLen = len(empty) - i
+			break
+		}
+	}
+	if suffixLen < 0 {
+		return nil, errors.New("could not find the suffix length")
+	}
+
+	suffix := make([]byte, 0, suffixLen)
+	for i := 0; i < suffixLen; i++ {
+		// Push the next unknown byte to the end of a block
+		fill := repeatByte(fillByte, size-1-i%size)
+		n := i / size
+
+		ciphertext, err := a.query(fill)
+		if err != nil {
+			return nil, err
+		}
+		target := ciphertext[n*size : (n+1)*size]
+
+		// Encrypt a block for every candidate byte in one query. Each
+		// block is the last size-1 known bytes followed by the candidate.
+		known := append(fill, suffix...)
+		known = known[len(known)-(size-1):]
+		candidates := make([]byte, 0, 256*size)
+		for b := 0; b < 256; b++ {
+			candidates = append(candidates, known...)
+			candidates = append(candidates, byte(b))
+		}
+		dict, err := a.query(candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for b := 0; b < 256; b++ {
+			if string(dict[b*size:(b+1)*size]) == string(target) {
+				suffix = append(suffix, byte(b))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no match for suffix byte %d", i)
+		}
+	}
+	return suffix, nil
+}
+
+// repeatByte returns n copies of b
+func repeatByte(b byte, n int) []byte {
+	return stdBytes.Repeat([]byte{b}, n)
+}
+
+// Modes DetectMode can tell apart
+const (
+	ModeECB     = "ECB"
+	ModeCBC     = "CBC"
+	ModeCTR     = "CTR"
+	ModeUnknown = "unknown"
+)
+
+// DetectMode works out which mode a black-box encryption oracle uses from
+// chosen plaintexts, and returns it along with a confidence between 0
+// and 1. Stream modes like CTR are reported as ModeCTR if the keystream
+// repeats across calls, and as ModeUnknown if it doesn't.
+func DetectMode(oracle func(plaintext []byte) []byte) (string, float64) {
+	// Block modes pad their output to a multiple of the block size, so the
+	// output lengths for many input lengths share the block size as a
+	// common divisor. Stream modes' output lengths don't.
+	size := 0
+	for n := 0; n <= 2*maxBlockSize; n++ {
+		size = gcd(size, len(oracle(repeatByte(fillByte, n))))
+	}
+	if size == 0 {
+		return ModeUnknown, 0
+	}
+
+	if size < 4 {
+		// With a fixed key and nonce, XORing two ciphertexts cancels the
+		// keystream out
+		a := oracle(repeatByte('A', 4*maxBlockSize))
+		b := oracle(repeatByte('B', 4*maxBlockSize))
+		matches := 0
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if a[i]^b[i] == 'A'^'B' {
+				matches++
+			}
+		}
+		if matches < 4*maxBlockSize {
+			// A fresh keystream per call, or something that isn't a
+			// stream cipher at all
+			return ModeUnknown, 0
+		}
+		return ModeCTR, .99
+	}
+
+	// Identical plaintext blocks encrypt to identical ciphertext blocks
+	// only under ECB. 4 blocks of input fill at least 3 whole blocks
+	// wherever a prefix ends.
+	repeats, err := bytes.RepeatedBlocks(oracle(repeatByte(markerByte, 4*size)), size)
+	if err == nil && repeats > 0 {
+		return ModeECB, .99
+	}
+
+	// Under CBC, changing one plaintext block changes its ciphertext block
+	// and every block after it. This can only be seen if encryption is
+	// deterministic, i.e. there's no random IV or prefix.
+	x := repeatByte(fillByte, 4*size)
+	c1 := oracle(x)
+	if string(c1) != string(oracle(x)) {
+		return ModeCBC, .6
+	}
+
+	y := repeatByte(fillByte, 4*size)
+	y[size+1] ^= 1
+	c2 := oracle(y)
+	if len(c1) != len(c2) {
+		return ModeUnknown, 0
+	}
+
+	first := -1
+	for i := 0; i < len(c1); i += size {
+		same := string(c1[i:i+size]) == string(c2[i:i+size])
+		if first < 0 && !same {
+			first = i
+		}
+		if first >= 0 && same {
+			return ModeUnknown, .5
+		}
+	}
+	if first < 0 {
+		return ModeUnknown, .5
+	}
+	return ModeCBC, .95
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// EcbCandidate is a ciphertext's ranking by LooksLikeECB. Repeats is the
+// number of blocks that repeat an earlier block, and Score is the fraction
+// of blocks after the first that do.
+type EcbCandidate struct {
+	Index   int
+	Repeats int
+	Score   float64
+}
+
+// LooksLikeECB ranks a corpus of ciphertexts by how likely each is to have
+// been encrypted under ECB with the given block size, most likely first.
+// Repeated blocks are practically impossible under other modes, so any
+// ciphertext with repeats is very likely ECB.
+func LooksLikeECB(ciphertexts [][]byte, blockSize int) ([]EcbCandidate, error) {
+	candidates := make([]EcbCandidate, len(ciphertexts))
+	for i, c := range ciphertexts {
+		candidates[i].Index = i
+		if len(c) == 0 {
+			continue
+		}
+
+		repeats, err := bytes.RepeatedBlocks(c, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		candidates[i].Repeats = repeats
+
+		n := (len(c) + blockSize - 1) / blockSize
+		if n > 1 {
+			candidates[i].Score = float64(repeats) / float64(n-1)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Repeats != candidates[j].Repeats {
+			return candidates[i].Repeats > candidates[j].Repeats
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
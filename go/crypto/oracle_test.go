@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"testing"
+
+	"github.com/taravancil/cryptopals/blocks"
+	"github.com/taravancil/cryptopals/bytes"
+)
+
+func TestPaddingOracleDecrypt(t *testing.T) {
+	key := NewAesKey()
+	oracle := NewCbcPaddingOracle(key)
+
+	// Plaintexts whose last block is full padding, and whose second to
+	// last byte happens to equal a possible padding byte
+	for _, plaintext := range []string{
+		"",
+		"Like your mother and your father too.",
+		"YELLOW SUBMARINE",
+		"ends with \x02",
+	} {
+		iv, _ := bytes.Random(aes.BlockSize)
+		ciphertext, _ := CbcEncrypt([]byte(plaintext), key, iv)
+
+		decrypted, err := PaddingOracleDecrypt(oracle, iv, ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decrypted) != plaintext {
+			t.Errorf("expected %q, got %q", plaintext, decrypted)
+		}
+	}
+
+	_, err := PaddingOracleDecrypt(oracle, make([]byte, 16), make([]byte, 15))
+	if err == nil {
+		t.Error("should fail given a partial block")
+	}
+}
+
+func TestPaddingOracleEncrypt(t *testing.T) {
+	key := NewAesKey()
+	oracle := NewCbcPaddingOracle(key)
+	plaintext := "All grown up but they're just like you."
+
+	iv, ciphertext, err := PaddingOracleEncrypt(oracle, []byte(plaintext), aes.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, _ := CbcDecrypt(ciphertext, key, iv)
+	decrypted = decrypted[:len(decrypted)-int(decrypted[len(decrypted)-1])]
+	if string(decrypted) != plaintext {
+		t.Errorf("expected %q, got %q", plaintext, decrypted)
+	}
+}
+
+func TestRecoverEcbSuffix(t *testing.T) {
+	key := NewAesKey()
+	secret := []byte("Rollin' in my 5.0\nWith my rag-top down so my hair can blow")
+	fixedPrefix, _ := bytes.Random(37)
+
+	oracles := map[string]EcbSuffixOracleFunc{
+		"no prefix": func(plaintext []byte) ([]byte, error) {
+			return EcbEncrypt(append(append([]byte{}, plaintext...), secret...), key)
+		},
+		"fixed prefix": func(plaintext []byte) ([]byte, error) {
+			input := append(append(append([]byte{}, fixedPrefix...), plaintext...), secret...)
+			return EcbEncrypt(input, key)
+		},
+		"random prefix": func(plaintext []byte) ([]byte, error) {
+			prefix, _ := bytes.Random(r.Intn(40))
+			input := append(append(prefix, plaintext...), secret...)
+			return EcbEncrypt(input, key)
+		},
+	}
+
+	for name, oracle := range oracles {
+		size, err := DetectEcbBlockSize(oracle)
+		if err != nil || size != aes.BlockSize {
+			t.Errorf("%s: expected block size %d, got %d (%v)", name, aes.BlockSize, size, err)
+		}
+
+		recovered, err := RecoverEcbSuffix(oracle)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(recovered) != string(secret) {
+			t.Errorf("%s: expected %q, got %q", name, secret, recovered)
+		}
+	}
+
+	// A CBC oracle never produces repeated blocks
+	iv, _ := bytes.Random(aes.BlockSize)
+	cbc := EcbSuffixOracleFunc(func(plaintext []byte) ([]byte, error) {
+		return CbcEncrypt(append(append([]byte{}, plaintext...), secret...), key, iv)
+	})
+	if _, err := RecoverEcbSuffix(cbc); err == nil {
+		t.Error("should fail against a CBC oracle")
+	}
+}
+
+func TestDetectMode(t *testing.T) {
+	key := NewAesKey()
+	iv, _ := bytes.Random(aes.BlockSize)
+
+	oracles := map[string]func([]byte) []byte{
+		ModeECB: func(plaintext []byte) []byte {
+			c, _ := EcbEncrypt(append([]byte{}, plaintext...), key)
+			return c
+		},
+		ModeCBC: func(plaintext []byte) []byte {
+			c, _ := CbcEncrypt(append([]byte{}, plaintext...), key, iv)
+			return c
+		},
+		ModeCTR: func(plaintext []byte) []byte {
+			stream, _ := Ctr(0, key)
+			c := make([]byte, len(plaintext))
+			stream.XORKeyStream(c, plaintext)
+			return c
+		},
+	}
+	for expected, oracle := range oracles {
+		mode, confidence := DetectMode(oracle)
+		if mode != expected {
+			t.Errorf("expected %s, got %s", expected, mode)
+		}
+		if confidence < .9 {
+			t.Errorf("%s: expected high confidence, got %f", expected, confidence)
+		}
+	}
+
+	// Random bytes around the input, as in challenge 11
+	for i := 0; i < 10; i++ {
+		encrypter, expected, _ := RandomAesMode()
+		oracle := func(plaintext []byte) []byte {
+			before, _ := bytes.Random(r.Intn(5) + 5)
+			after, _ := bytes.Random(r.Intn(5) + 5)
+			input := append(append(before, plaintext...), after...)
+			input, _ = blocks.Pkcs7(input, aes.BlockSize)
+			c := make([]byte, len(input))
+			encrypter.CryptBlocks(c, input)
+			return c
+		}
+		if mode, _ := DetectMode(oracle); mode != expected {
+			t.Errorf("expected %s, got %s", expected, mode)
+		}
+	}
+
+	// A deterministic block mode that doesn't chain
+	mode, _ := DetectMode(func(plaintext []byte) []byte {
+		c, _ := EcbEncrypt(append([]byte{}, plaintext...), key)
+		for i := range c {
+			c[i] ^= byte(i / aes.BlockSize)
+		}
+		return c
+	})
+	if mode != ModeUnknown {
+		t.Errorf("expected %s, got %s", ModeUnknown, mode)
+	}
+
+	// A stream cipher with a fresh nonce per call leaves no evidence
+	mode, confidence := DetectMode(func(plaintext []byte) []byte {
+		stream, _ := Ctr(r.Uint64(), key)
+		c := make([]byte, len(plaintext))
+		stream.XORKeyStream(c, plaintext)
+		return c
+	})
+	if mode != ModeUnknown || confidence != 0 {
+		t.Errorf("expected %s with no confidence, got %s with %f", ModeUnknown, mode, confidence)
+	}
+}
+
+func TestLooksLikeECB(t *testing.T) {
+	key := NewAesKey()
+	iv, _ := bytes.Random(aes.BlockSize)
+	plaintext := []byte("YELLOW SUBMARINEYELLOW SUBMARINEYELLOW SUBMARINE and some more")
+
+	ecb, _ := EcbEncrypt(append([]byte{}, plaintext...), key)
+	cbc, _ := CbcEncrypt(append([]byte{}, plaintext...), key, iv)
+	random, _ := bytes.Random(len(ecb))
+
+	candidates, err := LooksLikeECB([][]byte{cbc, random, ecb, nil}, aes.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 4 {
+		t.Fatalf("expected every ciphertext to be ranked, got %d", len(candidates))
+	}
+	if candidates[0].Index != 2 || candidates[0].Repeats != 2 {
+		t.Errorf("expected the ECB ciphertext first with 2 repeats, got %+v", candidates[0])
+	}
+	if candidates[1].Repeats != 0 {
+		t.Errorf("expected no repeats outside of ECB, got %+v", candidates[1])
+	}
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"crypto/aes"
+	"errors"
+
+	"github.com/taravancil/cryptopals/bytes"
+	"github.com/taravancil/cryptopals/rijndael"
+)
+
+// SquareRounds is the number of AES rounds BreakSquare attacks
+const SquareRounds = 4
+
+// How many Λ-sets BreakSquare tries before giving up
+const maxLambdaSets = 16
+
+// BreakSquare recovers the AES-128 key of 4-round AES given a chosen
+// plaintext encryption oracle, using the square (integral) attack.
+//
+// A Λ-set is 256 plaintexts that take every value in one byte and are
+// constant elsewhere. Three rounds later every byte of the state XORs to
+// zero over the set. The fourth round has no MixColumns, so each byte of
+// the last round key can be guessed on its own: a right guess undoes the
+// last SubBytes into bytes that XOR to zero, and a wrong one only does so
+// by chance. A few Λ-sets leave one guess per byte, and inverting the key
+// schedule from the last round key gives the key.
+func BreakSquare(oracle func([]byte) []byte) ([]byte, error) {
+	var candidates [aes.BlockSize][]byte
+	for i := range candidates {
+		candidates[i] = make([]byte, 256)
+		for k := range candidates[i] {
+			candidates[i][k] = byte(k)
+		}
+	}
+
+	for set := 0; set < maxLambdaSets; set++ {
+		ciphertexts, err := lambdaSet(oracle)
+		if err != nil {
+			return nil, err
+		}
+
+		done := true
+		for i := range candidates {
+			candidates[i] = balancedGuesses(ciphertexts, i, candidates[i])
+			if len(candidates[i]) == 0 {
+				return nil, errors.New("no last round key byte is balanced; is the oracle 4-round AES?")
+			}
+			if len(candidates[i]) > 1 {
+				done = false
+			}
+		}
+		if !done {
+			continue
+		}
+
+		var lastKey rijndael.State
+		for i := range lastKey {
+			lastKey[i] = candidates[i][0]
+		}
+		key, err := rijndael.InvertKeySchedule(lastKey, SquareRounds)
+		if err != nil {
+			return nil, err
+		}
+		if !squareKeyMatches(oracle, key) {
+			return nil, errors.New("recovered key doesn't match the oracle")
+		}
+		return key, nil
+	}
+	return nil, errors.New("too many Λ-sets without a unique key")
+}
+
+// lambdaSet encrypts a Λ-set whose first byte is active and whose other
+// bytes are random
+func lambdaSet(oracle func([]byte) []byte) ([][]byte, error) {
+	constant, err := bytes.Random(aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertexts := make([][]byte, 256)
+	for i := range ciphertexts {
+		plaintext := append([]byte{}, constant...)
+		plaintext[0] = byte(i)
+		ciphertexts[i] = oracle(plaintext)
+		if len(ciphertexts[i]) != aes.BlockSize {
+			return nil, errors.New("oracle must return one block")
+		}
+	}
+	return ciphertexts, nil
+}
+
+// balancedGuesses returns the guesses for byte i of the last round key
+// under which byte i of the state before the last SubBytes XORs to zero
+// over the Λ-set
+func balancedGuesses(ciphertexts [][]byte, i int, guesses []byte) []byte {
+	var kept []byte
+	for _, k := range guesses {
+		var sum byte
+		for _, c := range ciphertexts {
+			sum ^= rijndael.InvSBox(c[i] ^ k)
+		}
+		if sum == 0 {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+// squareKeyMatches checks a recovered key against one more oracle query
+func squareKeyMatches(oracle func([]byte) []byte, key []byte) bool {
+	c, err := NewAesRounds(key, SquareRounds)
+	if err != nil {
+		return false
+	}
+	plaintext, _ := bytes.Random(aes.BlockSize)
+	expected := make([]byte, aes.BlockSize)
+	c.Encrypt(expected, plaintext)
+	return stdBytes.Equal(oracle(plaintext), expected)
+}
@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"crypto/aes"
+	"testing"
+	"time"
+)
+
+func TestBreakSquare(t *testing.T) {
+	key := NewAesKey()
+	block, err := NewAesRounds(key, SquareRounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := 0
+	oracle := func(plaintext []byte) []byte {
+		queries++
+		c := make([]byte, aes.BlockSize)
+		block.Encrypt(c, plaintext)
+		return c
+	}
+
+	start := time.Now()
+	recovered, err := BreakSquare(oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stdBytes.Equal(recovered, key) {
+		t.Errorf("expected key %x, got %x", key, recovered)
+	}
+	t.Logf("recovered key with %d queries in %v", queries, time.Since(start))
+
+	// Five rounds are out of reach
+	block, _ = NewAesRounds(key, 5)
+	if _, err := BreakSquare(oracle); err == nil {
+		t.Error("should fail against 5-round AES")
+	}
+}
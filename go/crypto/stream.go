@@ -0,0 +1,201 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/taravancil/cryptopals/blocks"
+)
+
+// How much data the streaming wrappers buffer at a time, rounded down to a
+// multiple of the block size
+const streamChunk = 32 * 1024
+
+type blockModeWriter struct {
+	w      io.Writer
+	mode   cipher.BlockMode
+	size   int
+	buf    []byte
+	out    []byte
+	closed bool
+}
+
+// NewECBEncryptWriter returns a writer that encrypts everything written to
+// it under ECB mode with b and writes the ciphertext to w. Close pads the
+// last block with PKCS#7 and must be called to finish the ciphertext. It
+// doesn't close w.
+func NewECBEncryptWriter(w io.Writer, b cipher.Block) io.WriteCloser {
+	return newBlockModeWriter(w, NewECBEncrypter(b))
+}
+
+// NewCBCEncryptWriter is like NewECBEncryptWriter for CBC mode
+func NewCBCEncryptWriter(w io.Writer, b cipher.Block, iv []byte) (io.WriteCloser, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("iv must be %d bytes", b.BlockSize())
+	}
+	return newBlockModeWriter(w, cipher.NewCBCEncrypter(b, iv)), nil
+}
+
+func newBlockModeWriter(w io.Writer, mode cipher.BlockMode) *blockModeWriter {
+	size := mode.BlockSize()
+	chunk := streamChunk - streamChunk%size
+	return &blockModeWriter{
+		w:    w,
+		mode: mode,
+		size: size,
+		buf:  make([]byte, 0, chunk),
+		out:  make([]byte, chunk+size),
+	}
+}
+
+func (s *blockModeWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+
+		// Keep partial blocks until more data or Close fills them
+		full := len(s.buf) - len(s.buf)%s.size
+		if full == 0 {
+			continue
+		}
+		s.mode.CryptBlocks(s.out[:full], s.buf[:full])
+		if _, err := s.w.Write(s.out[:full]); err != nil {
+			return written, err
+		}
+		s.buf = s.buf[:copy(s.buf, s.buf[full:])]
+	}
+	return written, nil
+}
+
+// Close pads and encrypts whatever is left and writes the final block
+func (s *blockModeWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	padded, err := blocks.Pkcs7(s.buf, s.size)
+	if err != nil {
+		return err
+	}
+	s.mode.CryptBlocks(s.out[:len(padded)], padded)
+	_, err = s.w.Write(s.out[:len(padded)])
+	return err
+}
+
+type blockModeReader struct {
+	r    io.Reader
+	mode cipher.BlockMode
+	size int
+
+	// Ciphertext that hasn't been decrypted yet, at most one chunk
+	in []byte
+
+	// Decrypted plaintext waiting to be read
+	out    []byte
+	outBuf []byte
+
+	// The last decrypted block, which is only released once there is more
+	// ciphertext after it or its padding has been checked at EOF
+	held []byte
+
+	err error
+}
+
+// NewECBDecryptReader returns a reader that decrypts the ciphertext read
+// from r under ECB mode with b. The PKCS#7 padding of the last block is
+// checked and removed at the end of the stream, so a final Read returns an
+// error instead of io.EOF if the padding is invalid.
+func NewECBDecryptReader(r io.Reader, b cipher.Block) io.Reader {
+	return newBlockModeReader(r, NewECBDecrypter(b))
+}
+
+// NewCBCDecryptReader is like NewECBDecryptReader for CBC mode
+func NewCBCDecryptReader(r io.Reader, b cipher.Block, iv []byte) (io.Reader, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("iv must be %d bytes", b.BlockSize())
+	}
+	return newBlockModeReader(r, cipher.NewCBCDecrypter(b, iv)), nil
+}
+
+func newBlockModeReader(r io.Reader, mode cipher.BlockMode) *blockModeReader {
+	size := mode.BlockSize()
+	chunk := streamChunk - streamChunk%size
+	return &blockModeReader{
+		r:      r,
+		mode:   mode,
+		size:   size,
+		in:     make([]byte, 0, chunk),
+		outBuf: make([]byte, chunk+size),
+		held:   make([]byte, 0, size),
+	}
+}
+
+func (s *blockModeReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.fill()
+	}
+
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// fill reads more ciphertext and decrypts the full blocks it completes
+func (s *blockModeReader) fill() {
+	n, err := s.r.Read(s.in[len(s.in):cap(s.in)])
+	s.in = s.in[:len(s.in)+n]
+
+	out := s.outBuf[:0]
+	full := len(s.in) - len(s.in)%s.size
+	if full > 0 {
+		// There's more ciphertext, so the held block isn't the last one
+		out = append(out, s.held...)
+		start := len(out)
+		out = out[:start+full]
+		s.mode.CryptBlocks(out[start:], s.in[:full])
+
+		s.held = append(s.held[:0], out[len(out)-s.size:]...)
+		out = out[:len(out)-s.size]
+		s.in = s.in[:copy(s.in, s.in[full:])]
+	}
+
+	switch {
+	case err == io.EOF:
+		s.err = s.finish(&out)
+	case err != nil:
+		s.err = err
+	}
+	s.out = out
+}
+
+// finish checks and strips the padding of the last block at the end of the
+// ciphertext
+func (s *blockModeReader) finish(out *[]byte) error {
+	if len(s.in) != 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if len(s.held) == 0 {
+		return errors.New("empty ciphertext")
+	}
+
+	stripped, err := blocks.StripIfValidPkcs7Blocksize(s.held, s.size)
+	if err != nil {
+		return err
+	}
+	*out = append(*out, stripped...)
+	s.held = s.held[:0]
+	return io.EOF
+}
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	stdBytes "bytes"
+	"crypto/aes"
+	"crypto/des"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/taravancil/cryptopals/bytes"
+)
+
+func TestCBCStream(t *testing.T) {
+	block, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	iv, _ := bytes.Random(aes.BlockSize)
+
+	for _, length := range []int{0, 1, 15, 16, 17, 100, streamChunk - 1, streamChunk, 3*streamChunk + 5} {
+		plaintext, _ := bytes.Random(length)
+
+		var buf stdBytes.Buffer
+		w, err := NewCBCEncryptWriter(&buf, block, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write in uneven pieces
+		for p := plaintext; len(p) > 0; {
+			n := 7 + len(p)%1000
+			if n > len(p) {
+				n = len(p)
+			}
+			w.Write(p[:n])
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		expected, _ := CbcEncryptBlock(append([]byte{}, plaintext...), block, iv)
+		if !stdBytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("length %d: streamed ciphertext differs from CbcEncryptBlock", length)
+		}
+
+		r, _ := NewCBCDecryptReader(iotest.HalfReader(&buf), block, iv)
+		decrypted, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("length %d: %v", length, err)
+		}
+		if !stdBytes.Equal(decrypted, plaintext) {
+			t.Errorf("length %d: decrypted plaintext differs", length)
+		}
+	}
+
+	if _, err := NewCBCEncryptWriter(ioutil.Discard, block, iv[1:]); err == nil {
+		t.Error("should fail given an iv of the wrong size")
+	}
+}
+
+func TestECBStream(t *testing.T) {
+	block, _ := des.NewCipher([]byte("8bytekey"))
+	plaintext := []byte("Like your mother and your father too.")
+
+	var buf stdBytes.Buffer
+	w := NewECBEncryptWriter(&buf, block)
+	w.Write(plaintext)
+	w.Close()
+	if _, err := w.Write(plaintext); err == nil {
+		t.Error("should fail to write after Close")
+	}
+
+	expected, _ := EcbEncryptBlock(plaintext, block)
+	if !stdBytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("expected %x, got %x", expected, buf.Bytes())
+	}
+
+	decrypted, err := ioutil.ReadAll(NewECBDecryptReader(iotest.OneByteReader(&buf), block))
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("expected %s, got %s (%v)", plaintext, decrypted, err)
+	}
+}
+
+func TestDecryptReaderErrors(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	block, _ := aes.NewCipher(key)
+	iv := make([]byte, aes.BlockSize)
+
+	// The last block decrypts to invalid padding
+	ciphertext, _ := CbcEncrypt([]byte("YELLOW SUBMARINE"), key, iv)
+	r, _ := NewCBCDecryptReader(stdBytes.NewReader(ciphertext[:16]), block, iv)
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("should fail on invalid padding")
+	}
+
+	r, _ = NewCBCDecryptReader(stdBytes.NewReader(ciphertext[:20]), block, iv)
+	if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF on a partial block, got %v", err)
+	}
+
+	r, _ = NewCBCDecryptReader(stdBytes.NewReader(nil), block, iv)
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("should fail on an empty ciphertext")
+	}
+}
+
+// A large stream goes through both wrappers without being held in memory
+func TestCBCStreamLarge(t *testing.T) {
+	block, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	iv := make([]byte, aes.BlockSize)
+	const size = 64 << 20
+
+	pr, pw := io.Pipe()
+	go func() {
+		w, _ := NewCBCEncryptWriter(pw, block, iv)
+		_, err := io.Copy(w, io.LimitReader(zeroReader{}, size))
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	r, _ := NewCBCDecryptReader(pr, block, iv)
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size {
+		t.Errorf("expected %d bytes, got %d", size, n)
+	}
+
+	expected := sha256.New()
+	io.Copy(expected, io.LimitReader(zeroReader{}, size))
+	if !stdBytes.Equal(h.Sum(nil), expected.Sum(nil)) {
+		t.Error("decrypted stream differs from the input")
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
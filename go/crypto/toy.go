@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// ToyBlockSize is the block size of the toy cipher in bytes
+const ToyBlockSize = 8
+
+const toyRounds = 4
+
+type toyCipher struct {
+	roundKeys [toyRounds]uint32
+}
+
+// NewToyCipher returns a toy block cipher with 8-byte blocks and an 8-byte
+// key: a 4-round Feistel network over two 32-bit halves. It's small enough
+// to follow by hand and offers no security, so it's only for demonstrating
+// attacks on modes with 8-byte blocks.
+func NewToyCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 8 {
+		return nil, fmt.Errorf("invalid toy cipher key size %d", len(key))
+	}
+
+	c := new(toyCipher)
+	k := binary.BigEndian.Uint64(key)
+	for i := range c.roundKeys {
+		c.roundKeys[i] = uint32(bits.RotateLeft64(k, 16*i))
+	}
+	return c, nil
+}
+
+func (c *toyCipher) BlockSize() int {
+	return ToyBlockSize
+}
+
+// round is the Feistel round function
+func (c *toyCipher) round(x, k uint32) uint32 {
+	x ^= k
+	x = bits.RotateLeft32(x, 5) + x*0x9e3779b1
+	return x ^ x>>15
+}
+
+func (c *toyCipher) Encrypt(dst, src []byte) {
+	if len(src) < ToyBlockSize || len(dst) < ToyBlockSize {
+		panic("toy cipher: input not full block")
+	}
+	l := binary.BigEndian.Uint32(src[0:4])
+	r := binary.BigEndian.Uint32(src[4:8])
+	for i := 0; i < toyRounds; i++ {
+		l, r = r, l^c.round(r, c.roundKeys[i])
+	}
+	binary.BigEndian.PutUint32(dst[0:4], l)
+	binary.BigEndian.PutUint32(dst[4:8], r)
+}
+
+func (c *toyCipher) Decrypt(dst, src []byte) {
+	if len(src) < ToyBlockSize || len(dst) < ToyBlockSize {
+		panic("toy cipher: input not full block")
+	}
+	l := binary.BigEndian.Uint32(src[0:4])
+	r := binary.BigEndian.Uint32(src[4:8])
+	for i := toyRounds - 1; i >= 0; i-- {
+		l, r = r^c.round(l, c.roundKeys[i]), l
+	}
+	binary.BigEndian.PutUint32(dst[0:4], l)
+	binary.BigEndian.PutUint32(dst[4:8], r)
+}
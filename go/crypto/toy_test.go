@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestToyCipher(t *testing.T) {
+	block, err := NewToyCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("YELLOWSB")
+	ciphertext := make([]byte, ToyBlockSize)
+	block.Encrypt(ciphertext, plaintext)
+	if string(ciphertext) == string(plaintext) {
+		t.Error("encryption should change the block")
+	}
+
+	decrypted := make([]byte, ToyBlockSize)
+	block.Decrypt(decrypted, ciphertext)
+	if string(decrypted) != string(plaintext) {
+		t.Errorf("expected %s, got %s", plaintext, decrypted)
+	}
+
+	other, _ := NewToyCipher([]byte("8bytekez"))
+	other.Encrypt(decrypted, plaintext)
+	if string(decrypted) == string(ciphertext) {
+		t.Error("a different key should give a different ciphertext")
+	}
+
+	if _, err := NewToyCipher([]byte("short")); err == nil {
+		t.Error("should fail given a key that isn't 8 bytes")
+	}
+}
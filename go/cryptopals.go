@@ -6,16 +6,24 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"math/rand"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/taravancil/cryptopals/blocks"
 	"github.com/taravancil/cryptopals/bytes"
 	"github.com/taravancil/cryptopals/crypto"
+	"github.com/taravancil/cryptopals/dh"
+	"github.com/taravancil/cryptopals/md4"
 	"github.com/taravancil/cryptopals/profile"
+	"github.com/taravancil/cryptopals/sha1"
+	"github.com/taravancil/cryptopals/srp"
+	"github.com/taravancil/cryptopals/timing"
 	"github.com/taravancil/cryptopals/utils"
 )
 
@@ -29,7 +37,7 @@ type Challenge struct {
 type Result interface{}
 
 func main() {
-	var done = []func() (Result, Result){c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13, c14, c15, c16, c17, c18, c19, c20, c21}
+	var done = []func() (Result, Result){c1, c2, c3, c4, c5, c6, c7, c8, c9, c10, c11, c12, c13, c14, c15, c16, c17, c18, c19, c20, c21, c22, c23, c24, c25, c26, c27, c28, c29, c30, c31, c32, c33, c34, c35, c36, c37, c38}
 
 	for i, chal := range done {
 		var c Challenge
@@ -87,30 +95,24 @@ func c2() (actual, expected Result) {
  */
 func c3() (actual, expected Result) {
 	input := "1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736"
-	expected = string([]byte{99, 79, 79, 75, 73, 78, 71, 0, 109, 99, 7, 83, 0, 76, 73, 75, 69, 0, 65, 0, 80, 79, 85, 78, 68, 0, 79, 70, 0, 66, 65, 67, 79, 78})
+	expected = "Cooking MC's like a pound of bacon"
 
 	ciphertext, err := hex.DecodeString(input)
 	if err != nil {
 		panic(err)
 	}
 
-	// Assume the most popular byte in the ciphertext is the key
-	key, err := bytes.Popular(ciphertext, 1)
+	best, err := crypto.BreakSingleByteXor(ciphertext, crypto.EnglishScorer, 1)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	result, err := bytes.XorRepeatingKey(ciphertext, key)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return string(result), expected
+	return string(best[0].Plaintext), expected
 }
 
 // Detect single-character XOR
 func c4() (actual, expected Result) {
-	expected = string([]byte{110, 79, 87, 0, 84, 72, 65, 84, 0, 84, 72, 69, 0, 80, 65, 82, 84, 89, 0, 73, 83, 0, 74, 85, 77, 80, 73, 78, 71, 42})
+	expected = "Now that the party is jumping\n"
 
 	input, err := ioutil.ReadFile("input/4.txt")
 	if err != nil {
@@ -118,10 +120,9 @@ func c4() (actual, expected Result) {
 	}
 
 	lines := strings.Split(string(input), "\n")
-	var bestScore = 0.0
-	var plaintext string
+	var best *crypto.SingleByteXorCandidate
 
-	// Find the most popular byte in each line
+	// Break each line and keep the most English-looking result
 	for i := 0; i < len(lines)-1; i++ {
 		line, err := hex.DecodeString(lines[i])
 		if err != nil {
@@ -129,27 +130,16 @@ func c4() (actual, expected Result) {
 			continue
 		}
 
-		key, err := bytes.Popular(line, 1)
+		candidates, err := crypto.BreakSingleByteXor(line, crypto.EnglishScorer, 1)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
-
-		decrypted, err := bytes.XorRepeatingKey(line, key)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		// Score each string for how likely it is English
-		decryptedStr := string(decrypted)
-		score := utils.EnglishScore(decryptedStr)
-		if score > bestScore {
-			bestScore = score
-			plaintext = decryptedStr
+		if best == nil || candidates[0].Score > best.Score {
+			best = &candidates[0]
 		}
 	}
-	return plaintext, expected
+	return string(best.Plaintext), expected
 }
 
 // Implement repeating-key XOR
@@ -223,18 +213,18 @@ func c8() (actual, expected Result) {
 
 	hexStrings := strings.Split(string(input), "\n")
 	ciphertexts := make([][]byte, len(hexStrings)-1)
-
-	for i := 0; i < len(ciphertexts); i++ {
+	for i := range ciphertexts {
 		ciphertexts[i], _ = hex.DecodeString(hexStrings[i])
-		ecb, err := bytes.HasRepeatedBlock(ciphertexts[i], aes.BlockSize)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if ecb {
-			return i, expected
-		}
 	}
-	return -1, expected
+
+	candidates, err := crypto.LooksLikeECB(ciphertexts, aes.BlockSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if candidates[0].Repeats == 0 {
+		return -1, expected
+	}
+	return candidates[0].Index, expected
 }
 
 // Implement PKCS#/ padding
@@ -269,22 +259,26 @@ func c10() (actual, expected Result) {
 * randomly choose to encrypt under CBC or ECB mode. Detect which.
  */
 func c11() (actual, expected Result) {
-	plaintext := []byte("Put your good face on, not foolin' no one. You're a jackrabbit underneath.")
-
 	encrypter, actualMode, err := crypto.RandomAesMode()
 	if err != nil {
 		log.Println(err)
 	}
 
-	mode := crypto.AesOracle(plaintext, encrypter)
+	oracle := func(input []byte) []byte {
+		prependBytes, _ := bytes.Random(r.Intn(5) + 5)
+		appendBytes, _ := bytes.Random(r.Intn(5) + 5)
 
-	switch actualMode {
-	case "CBC":
-		expected = "CBC"
-	case "ECB":
-		expected = "ECB"
+		plaintext := append(prependBytes, input...)
+		plaintext = append(plaintext, appendBytes...)
+		plaintext, _ = blocks.Pkcs7(plaintext, aes.BlockSize)
+
+		ciphertext := make([]byte, len(plaintext))
+		encrypter.CryptBlocks(ciphertext, plaintext)
+		return ciphertext
 	}
-	return mode, expected
+
+	mode, _ := crypto.DetectMode(oracle)
+	return mode, actualMode
 }
 
 /* Byte-at-a-time ECB decryption
@@ -293,43 +287,16 @@ func c11() (actual, expected Result) {
 * AES-128-ECB(known-string || unknown-string, key)
  */
 func c12() (actual, expected Result) {
-	expected = "Rollin' in my 5.0\nWith my rag-top down so my hair can blow\nThe girlies on standby waving just to say hi\nDid you stop? No, I just drove by\n\x01"
-
-	if crypto.GlobalAesKey == nil {
-		crypto.GlobalAesKey = crypto.NewAesKey()
-	}
-	key := crypto.GlobalAesKey
+	expected = "Rollin' in my 5.0\nWith my rag-top down so my hair can blow\nThe girlies on standby waving just to say hi\nDid you stop? No, I just drove by\n"
 
-	blocksize := crypto.DetectBlocksize(key)
-	secretBlocks := len(crypto.AppendSecretEncryptEcb([]byte(""), key, false)) / blocksize
-
-	var secret []byte
+	key := crypto.NewAesKey()
+	oracle := crypto.EcbSuffixOracleFunc(func(plaintext []byte) ([]byte, error) {
+		return crypto.AppendSecretEncryptEcb(plaintext, key, false), nil
+	})
 
-	createDict := func(plaintext []byte, block int) map[int][]byte {
-		dict := make(map[int][]byte)
-		for b := 0; b <= 255; b++ {
-			extra := []byte{byte(b)}
-			plaintext := append(plaintext, extra[0])
-			ciphertext := crypto.AppendSecretEncryptEcb(plaintext, key, false)
-			dict[b] = ciphertext[block*blocksize : blocksize*(block+1)]
-		}
-		return dict
-	}
-
-	for n := 0; n < secretBlocks; n++ {
-		for i := 0; i < blocksize; i++ {
-			short := stdBytes.Repeat([]byte("A"), blocksize-(i+1))
-			plaintext := append(short, secret...)
-			dict := createDict(plaintext, n)
-			secretCiphertext := crypto.AppendSecretEncryptEcb(short, key, false)
-
-			for char, lookup := range dict {
-				if string(secretCiphertext[n*blocksize:blocksize*(n+1)]) == string(lookup) {
-					char := []byte{byte(char)}
-					secret = append(secret, char...)
-				}
-			}
-		}
+	secret, err := crypto.RecoverEcbSuffix(oracle)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return string(secret), expected
 }
@@ -384,81 +351,16 @@ func c13() (actual, expected Result) {
 * AES-128-ECB(random-#-bytes || input, key)
  */
 func c14() (actual, expected Result) {
-	expected = "Rollin' in my 5.0\nWith my rag-top down so my hair can blow\nThe girlies on standby waving just to say hi\nDid you stop? No, I just drove by\n\x01"
-
-	if len(crypto.GlobalAesKey) == 0 {
-		crypto.GlobalAesKey = crypto.NewAesKey()
-	}
-	key := crypto.GlobalAesKey
+	expected = "Rollin' in my 5.0\nWith my rag-top down so my hair can blow\nThe girlies on standby waving just to say hi\nDid you stop? No, I just drove by\n"
 
-	blocksize := crypto.DetectBlocksize(key)
-
-	createDict := func(plaintext []byte, prefixLength, block int) map[int][]byte {
-		dict := make(map[int][]byte)
-		for b := 0; b <= 255; b++ {
-			extra := []byte{byte(b)}
-			plaintext := append(plaintext, extra[0])
-			ciphertext := crypto.AppendSecretEncryptEcb(plaintext, key, true)
-			dict[b] = ciphertext[(block*blocksize)+prefixLength : (blocksize*(block+1))+prefixLength]
-		}
-		return dict
-	}
-
-	findPrefixLength := func(blocksize int, key []byte) int {
-		// If we send 2-3 blocks of repeating bytes, we will see a repeating block
-		for i := blocksize * 2; i <= blocksize*3; i++ {
-			encrypted := crypto.AppendSecretEncryptEcb(stdBytes.Repeat([]byte("A"), i), key, true)
-			numBlocks := len(encrypted) / blocksize
-
-			// Loop through blocks to find a repeat
-			for j := 0; j < numBlocks-1; j++ {
-				firstBlock := encrypted[j*blocksize : (j+1)*blocksize]
-				secondBlock := encrypted[(j+1)*blocksize : (j+2)*blocksize]
+	key := crypto.NewAesKey()
+	oracle := crypto.EcbSuffixOracleFunc(func(plaintext []byte) ([]byte, error) {
+		return crypto.AppendSecretEncryptEcb(plaintext, key, true), nil
+	})
 
-				// Repeating block indicates we added enough bytes to make an even block
-				if string(firstBlock) == string(secondBlock) {
-					return (j+2)*blocksize - i
-				}
-			}
-		}
-		return 0
-	}
-
-	// Knowing the length of the random prefix bytes, pad input to make a full block
-	var secretBlocks int
-	prefix := findPrefixLength(blocksize, key)
-	// TODO: There must be a better way to account for prefix/16 rounding down and
-	// giving 1 too few blocks
-	if prefix%blocksize <= 5 {
-		secretBlocks += 1
-	}
-	pad := blocksize - (prefix % blocksize) // prefix + pad = full block
-	prefix += pad
-
-	// Figure out how many blocks to solve
-	totalBlocks := len(crypto.AppendSecretEncryptEcb([]byte(""), key, true)) / blocksize
-	secretBlocks += totalBlocks - (prefix / 16)
-	var secret []byte
-
-	for n := 0; n < secretBlocks; n++ {
-		for i := 0; i < blocksize; i++ {
-			// Send A*pad to pad the prefix to a full block, + A*blocksize-1,
-			// blocksize-2, ... until that block of the secret is solved
-			short := stdBytes.Repeat([]byte("A"), pad+blocksize-(i+1))
-			plaintext := append(short, secret...)
-
-			// Create a dictionary of ciphertexts for every character
-			dict := createDict(plaintext, prefix, n)
-			secretCiphertext := crypto.AppendSecretEncryptEcb(short, key, true)
-
-			for char, lookup := range dict {
-				targetBlock := secretCiphertext[prefix+(n*blocksize) : prefix+(blocksize*(n+1))]
-				if string(targetBlock) == string(lookup) {
-					char := []byte{byte(char)}
-					secret = append(secret, char...)
-				}
-			}
-		}
+	secret, err := crypto.RecoverEcbSuffix(oracle)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return string(secret), expected
 }
@@ -501,8 +403,8 @@ func c15() (actual, expected Result) {
 func c16() (actual, expected Result) {
 	key := crypto.NewAesKey()
 
-	input := "XadminXtrue"
-	inputBytes := []byte(input)
+	// The prefix is exactly two blocks, so the input fills the third block
+	input := "AAAAAAAAAAAA"
 	str := profile.ProcessComment(input)
 
 	iv, _ := bytes.Random(aes.BlockSize)
@@ -511,11 +413,12 @@ func c16() (actual, expected Result) {
 		panic(err)
 	}
 
-	// Flip the targeted bytes ("X"s in the input string)
-	encrypted[16] = encrypted[16] ^ 59 ^ inputBytes[0]
-	encrypted[22] = encrypted[22] ^ 61 ^ inputBytes[6]
+	forged, err := crypto.ForgeCBC(encrypted, aes.BlockSize, []byte(input), 32, []byte(";admin=true;"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	hasAdmin := profile.HasAdmin(encrypted, key, iv)
+	hasAdmin := profile.HasAdmin(forged, key, iv)
 	return hasAdmin, true
 }
 
@@ -530,10 +433,7 @@ func c17() (actual, expected Result) {
 	str := strs[r.Intn(10)]
 	decodedStr, _ := base64.StdEncoding.DecodeString(str)
 
-	if crypto.GlobalAesKey == nil {
-		crypto.GlobalAesKey = crypto.NewAesKey()
-	}
-	key := crypto.GlobalAesKey
+	key := crypto.NewAesKey()
 	iv, _ := bytes.Random(aes.BlockSize)
 
 	ciphertext, err := crypto.CbcEncrypt([]byte(decodedStr), key, iv)
@@ -541,56 +441,12 @@ func c17() (actual, expected Result) {
 		log.Fatal(err)
 	}
 
-	blocks, err := bytes.SplitIntoBlocks(ciphertext, aes.BlockSize)
+	plaintext, err := crypto.PaddingOracleDecrypt(crypto.NewCbcPaddingOracle(key), iv, ciphertext)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var plaintext []byte
-
-	for n := 0; n < len(blocks); n++ {
-		block := blocks[n]
-		controlled := make([]byte, aes.BlockSize)
-		plaintextBlock := make([]byte, aes.BlockSize)
-		intermediate := make([]byte, aes.BlockSize)
-		prevBlock := make([]byte, aes.BlockSize)
-
-		if n == 0 {
-			prevBlock = iv
-		} else {
-			prevBlock = blocks[n-1]
-		}
-
-		for i := aes.BlockSize - 1; i >= 0; i-- {
-			paddingLen := aes.BlockSize - i
-			paddingByte := byte(paddingLen)
-
-			// Set the last paddingLen bytes of controlled to so that when decrypted,
-			// each will be a valid padding byte.
-			for j := 0; j < paddingLen; j++ {
-				controlled[i+j] = paddingByte ^ intermediate[i+j]
-			}
-
-			for b := 0; b <= 256; b++ {
-				controlled[i] = byte(b)
-				controlled := append(controlled, block...)
-				valid, _ := crypto.CbcPaddingOracle(controlled, iv)
-				if valid {
-					// The padding is valid and we control the ith byte of the
-					// block XORed with the intermediate state. XOR is an inverse
-					// operation so finding the ith byte of the intermediate state
-					// is as simple as:
-					intermediate[i] = paddingByte ^ controlled[i]
-					break
-				}
-			}
-			plaintextBlock[i] = prevBlock[i] ^ intermediate[i]
-		}
-		plaintext = append(plaintext, plaintextBlock...)
-	}
-
-	decrypted, _ := crypto.CbcDecrypt(ciphertext, key, iv)
-	return string(plaintext), string(decrypted)
+	return string(plaintext), string(decodedStr)
 }
 
 // Implement AES in CTR mode
@@ -619,8 +475,38 @@ func c18() (actual, expected Result) {
 	return string(plaintext2), string(plaintext)
 }
 
+/* Break fixed-nonce CTR mode using substitutions
+ * Encrypt each line of 19.txt under CTR with the same key and nonce, then
+ * recover the keystream column by column. The last columns are shared by
+ * too few lines to score, so fill them in by guessing the end of the
+ * longest line.
+ */
 func c19() (actual, expected Result) {
-	return "not", "done"
+	input, _ := ioutil.ReadFile("input/19.txt")
+	strs := strings.Split(strings.TrimSpace(string(input)), "\n")
+	key := crypto.NewAesKey()
+
+	plaintexts := make([][]byte, len(strs))
+	ciphertexts := make([][]byte, len(strs))
+	for i, str := range strs {
+		plaintexts[i], _ = base64.StdEncoding.DecodeString(str)
+
+		stream, err := crypto.Ctr(0, key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ciphertexts[i] = make([]byte, len(plaintexts[i]))
+		stream.XORKeyStream(ciphertexts[i], plaintexts[i])
+	}
+
+	// "He, too, has been changed in his tu..."
+	hints := crypto.HintString(37, 33, "turn,")
+	broken, err := crypto.BreakFixedNonceCTR(ciphertexts, hints...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return string(stdBytes.Join(broken.Plaintexts, []byte("\n"))), string(stdBytes.Join(plaintexts, []byte("\n")))
 }
 
 /* Encrypt a set of strings in AES CTR mode using the same nonce
@@ -628,60 +514,432 @@ func c19() (actual, expected Result) {
  */
 func c20() (actual, expected Result) {
 	input, _ := ioutil.ReadFile("input/20.txt")
-	output, _ := utils.ReadAndStripFile("output/20.txt")
-	expected = string(output)
+	output, _ := ioutil.ReadFile("output/20.txt")
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 
-	strs := strings.Split(string(input), "\n")
-	n := len(strs)
-	strs = strs[:n-2]
+	strs := strings.Split(strings.TrimSpace(string(input)), "\n")
 	key := crypto.NewAesKey()
 	nonce := uint64(0)
 
-	// Decode strings and find length of the shortest string
-	length := 1000
-	plaintexts := make([][]byte, len(strs))
+	ciphertexts := make([][]byte, len(strs))
 	for i, str := range strs {
-		decoded, _ := base64.StdEncoding.DecodeString(str)
-		if len(decoded) < length {
-			length = len(decoded)
+		plaintext, _ := base64.StdEncoding.DecodeString(str)
+
+		stream, err := crypto.Ctr(nonce, key)
+		if err != nil {
+			log.Fatal(err)
 		}
-		plaintexts[i] = decoded
+		ciphertexts[i] = make([]byte, len(plaintext))
+		stream.XORKeyStream(ciphertexts[i], plaintext)
 	}
 
-	var concat []byte
-	for i, str := range plaintexts {
-		// Truncate each string to common length
-		plaintexts[i] = str[:length]
+	broken, err := crypto.BreakFixedNonceCTR(ciphertexts)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		stream, err := crypto.Ctr(nonce, key)
-		if err != nil {
-			log.Println(err)
+	// Only the longest lines reach the last columns, too few to score, so
+	// compare the columns before the first one the breaker isn't sure of
+	scored := len(broken.Confidence)
+	for i, confidence := range broken.Confidence {
+		if confidence < .9 {
+			scored = i
+			break
+		}
+	}
+
+	recovered := make([]string, len(broken.Plaintexts))
+	for i, plaintext := range broken.Plaintexts {
+		if len(plaintext) > scored {
+			plaintext = plaintext[:scored]
 		}
+		if len(lines[i]) > scored {
+			lines[i] = lines[i][:scored]
+		}
+		recovered[i] = string(plaintext)
+	}
+
+	return strings.Join(recovered, "\n"), strings.Join(lines, "\n")
+}
 
-		// Concatenate the ciphertexts
-		ciphertext := make([]byte, length)
-		stream.XORKeyStream(ciphertext, plaintexts[i])
-		concat = append(concat, ciphertext...)
+/* Implement the MT19937 Mersenne Twister RNG
+ * Check the generator against the reference implementation's first output
+ * for the default seed
+ */
+func c21() (actual, expected Result) {
+	expected = uint32(3499211612)
+
+	mt := crypto.NewMT19937(5489)
+	return mt.Uint32(), expected
+}
+
+/* Crack an MT19937 seed
+ * Seed MT19937 with the current Unix timestamp after a random wait, take
+ * its first output after another random wait, then recover the seed from
+ * the output alone. The waits are simulated rather than slept through.
+ */
+func c22() (actual, expected Result) {
+	now := time.Now().Add(time.Duration(r.Intn(960)+40) * time.Second)
+	seed := uint32(now.Unix())
+	output := crypto.NewMT19937(seed).Uint32()
+
+	now = now.Add(time.Duration(r.Intn(960)+40) * time.Second)
+	seeds := crypto.CrackTimeSeed(output, time.Hour, now)
+	if len(seeds) != 1 {
+		return fmt.Sprintf("%d candidate seeds", len(seeds)), seed
 	}
 
-	l := []int{length}
+	return seeds[0], seed
+}
 
-	plaintext, err := crypto.BreakXorRepeating(concat, l)
+/* Clone an MT19937 RNG from its output
+ * Untemper 624 outputs of a generator to recover its state, then predict
+ * its next outputs with the clone
+ */
+func c23() (actual, expected Result) {
+	mt := crypto.NewMT19937(uint32(r.Int63()))
+
+	outputs := make([]uint32, 624)
+	for i := range outputs {
+		outputs[i] = mt.Uint32()
+	}
+
+	clone, err := crypto.CloneMT19937(outputs)
 	if err != nil {
-		log.Println(err)
+		log.Fatal(err)
 	}
 
-	return string(utils.Strip(plaintext)), expected
+	return clone.Uint64(), mt.Uint64()
 }
 
-func c21() (actual, expected Result) {
-	now := int(time.Now().Unix())
-	n, err := crypto.MT19937(now)
+/* Create the MT19937 stream cipher and break it
+ * Encrypt a known plaintext behind a random prefix under a random 16-bit
+ * key and recover the key. Then generate a password reset token from a
+ * time-seeded MT19937 and detect it.
+ */
+func c24() (actual, expected Result) {
+	key := uint16(r.Intn(1 << 16))
+	known := stdBytes.Repeat([]byte("A"), 14)
+	prefix, _ := bytes.Random(r.Intn(20) + 5)
+
+	plaintext := append(prefix, known...)
+	ciphertext := make([]byte, len(plaintext))
+	crypto.MTStream(key).XORKeyStream(ciphertext, plaintext)
+
+	recovered, err := crypto.RecoverMTStreamKey(ciphertext, known)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+	token := make([]byte, 16)
+	crypto.NewMT19937(uint32(now.Unix())).Read(token)
+	if !crypto.IsMTTimeToken(token, time.Hour, now) {
+		return "token not detected", key
+	}
+
+	return recovered, key
+}
+
+/* Break "random access read/write" AES CTR
+ * Recover the plaintext of 7.txt, re-encrypted under CTR with a random key,
+ * through an edit function that patches the ciphertext in place
+ */
+func c25() (actual, expected Result) {
+	input, _ := ioutil.ReadFile("input/7.txt")
+	output, _ := utils.ReadAndStripFile("output/7.txt")
+	expected = string(output)
+
+	ecbCiphertext, err := base64.StdEncoding.DecodeString(string(input))
+	if err != nil {
+		log.Fatal(err)
+	}
+	plaintext, err := crypto.EcbDecrypt(ecbCiphertext, []byte("YELLOW SUBMARINE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key := crypto.NewAesKey()
+	stream, err := crypto.Ctr(0, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	stream.XORKeyStream(ciphertext, plaintext)
+
+	edit := func(ciphertext []byte, offset int, newtext []byte) ([]byte, error) {
+		return crypto.Edit(ciphertext, key, offset, newtext)
+	}
+	recovered, err := crypto.BreakRandomAccessCTR(ciphertext, edit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return string(utils.Strip(recovered)), expected
+}
+
+/* CTR bitflipping
+ * Redo the CBC bitflipping attack from #16 against a comment encrypted
+ * under CTR mode
+ */
+func c26() (actual, expected Result) {
+	key := crypto.NewAesKey()
+	nonce := uint64(r.Int63())
+
+	input := "AAAAAAAAAAAA"
+	str := profile.ProcessComment(input)
+
+	stream, err := crypto.Ctr(nonce, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encrypted := make([]byte, len(str))
+	stream.XORKeyStream(encrypted, []byte(str))
+
+	forged, err := crypto.ForgeCTR(encrypted, []byte(input), 32, []byte(";admin=true;"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return profile.HasAdminCtr(forged, key, nonce), true
+}
+
+/* Recover the key from CBC with IV=Key
+ * Encrypt a comment under CBC using the key as the IV. The receiver rejects
+ * messages with high-ASCII bytes and reports their plaintext, which is
+ * enough to recover the key.
+ */
+func c27() (actual, expected Result) {
+	key := crypto.NewAesKey()
+	str := profile.ProcessComment("nothing to see here")
+
+	ciphertext, err := crypto.CbcEncryptKeyIV([]byte(str), key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	receiver := func(ciphertext []byte) error {
+		return crypto.ReceiveKeyIV(ciphertext, key)
+	}
+	recovered, err := crypto.RecoverKeyIV(ciphertext, receiver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return hex.EncodeToString(recovered), hex.EncodeToString(key)
+}
+
+/* Implement a SHA-1 keyed MAC
+ * Sign a message with SHA-1(key || message) and check that tampering with
+ * the message invalidates the MAC
+ */
+func c28() (actual, expected Result) {
+	mac := crypto.NewSecretPrefixMAC(sha1.New, crypto.NewAesKey())
+	message := []byte("comment1=cooking%20MCs;userdata=foo")
+	signature := mac.Sign(message)
+
+	tampered := append([]byte{}, message...)
+	tampered[len(tampered)-1] ^= 1
+
+	return mac.Verify(message, signature) && !mac.Verify(tampered, signature), true
+}
+
+/* Break a SHA-1 keyed MAC using length extension
+ * Append ;admin=true to a signed comment and forge its MAC without knowing
+ * the key or its length
+ */
+func c29() (actual, expected Result) {
+	key, _ := bytes.Random(1 + r.Intn(32))
+	mac := crypto.NewSecretPrefixMAC(sha1.New, key)
+	message := []byte("comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon")
+
+	forged, forgedMAC, err := crypto.ForgeSHA1PrefixMAC(message, mac.Sign(message), []byte(";admin=true"), 32, mac.Verify)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return n, n
+	return profile.HasAdminMAC(forged, forgedMAC, mac), true
+}
+
+/* Break an MD4 keyed MAC using length extension
+ * The same attack as challenge 29, this time on a profile cookie
+ */
+func c30() (actual, expected Result) {
+	key, _ := bytes.Random(1 + r.Intn(32))
+	mac := crypto.NewSecretPrefixMAC(md4.New, key)
+	cookie := []byte(profile.New("foo@bar.com"))
+
+	forged, forgedMAC, err := crypto.ForgeMD4PrefixMAC(cookie, mac.Sign(cookie), []byte(";admin=true"), 32, mac.Verify)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return profile.HasAdminMAC(forged, forgedMAC, mac), true
+}
+
+/* Implement and break HMAC-SHA1 with an artificial timing leak
+ * The server sleeps 50ms after each matching byte of the signature
+ */
+func c31() (actual, expected Result) {
+	return breakTimingLeak(50 * time.Millisecond)
+}
+
+/* Break HMAC-SHA1 with a slightly less artificial timing leak
+ * The same attack as challenge 31 with a 5ms sleep
+ */
+func c32() (actual, expected Result) {
+	return breakTimingLeak(5 * time.Millisecond)
+}
+
+func breakTimingLeak(delay time.Duration) (actual, expected Result) {
+	key, _ := bytes.Random(16)
+	server := timing.NewServer(key, delay)
+	base, stop, err := server.ListenLoopback()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
+
+	file := "foo"
+	signature, err := timing.RecoverSignature(base, file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return hex.EncodeToString(signature), hex.EncodeToString(server.Sign(file))
+}
+
+/* Implement Diffie-Hellman
+ * Agree a shared secret in a toy group and in the NIST group
+ */
+func c33() (actual, expected Result) {
+	toy := &dh.Group{P: big.NewInt(37), G: big.NewInt(5)}
+	for _, group := range []*dh.Group{toy, dh.MODP1536} {
+		a, err := dh.GenerateKey(group, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := dh.GenerateKey(group, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if a.Shared(b.Public).Cmp(b.Shared(a.Public)) != 0 {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+/* Implement a MITM key-fixing attack on Diffie-Hellman with parameter injection
+ * Replace both public keys with p, so the shared secret is 0
+ */
+func c34() (actual, expected Result) {
+	message := "Ice, Ice, Baby"
+	_, read, err := dh.Simulate(dh.MODP1536, [][]byte{[]byte(message)}, dh.KeyFixing())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return string(read[0]), message
+}
+
+/* Implement DH with negotiated groups, and break with malicious "g" parameters
+ * Offer B a generator of 1, p or p-1 and read the messages that follow
+ */
+func c35() (actual, expected Result) {
+	message := "Ice, Ice, Baby"
+	generators := []func(p *big.Int) *big.Int{
+		func(*big.Int) *big.Int { return big.NewInt(1) },
+		func(p *big.Int) *big.Int { return p },
+		func(p *big.Int) *big.Int { return new(big.Int).Sub(p, big.NewInt(1)) },
+	}
+
+	for _, g := range generators {
+		_, read, err := dh.Simulate(dh.MODP1536, [][]byte{[]byte(message)}, dh.MaliciousGenerator(g))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if string(read[0]) != message {
+			return string(read[0]), message
+		}
+	}
+	return message, message
+}
+
+/* Implement Secure Remote Password (SRP)
+ * Log in with the right password, then fail to with a wrong one
+ */
+func c36() (actual, expected Result) {
+	server := srp.NewServer(dh.MODP1536)
+	if err := server.Register("foo@bar.com", "hunter2"); err != nil {
+		log.Fatal(err)
+	}
+
+	right := srp.NewClient(dh.MODP1536, "foo@bar.com", "hunter2")
+	wrong := srp.NewClient(dh.MODP1536, "foo@bar.com", "hunter3")
+	_, rightErr := srpLogin(server.Serve, right.Login)
+	_, wrongErr := srpLogin(server.Serve, wrong.Login)
+
+	return rightErr == nil && wrongErr == srp.ErrLoginFailed, true
+}
+
+/* Break SRP with a zero key
+ * Send A = 0, N or 2N and log in without the password
+ */
+func c37() (actual, expected Result) {
+	server := srp.NewServer(dh.MODP1536)
+	server.InsecureSkipCheckA = true
+	if err := server.Register("foo@bar.com", "hunter2"); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, multiple := range []int64{0, 1, 2} {
+		_, err := srpLogin(server.Serve, func(conn io.ReadWriter) error {
+			return srp.LoginWithoutPassword(conn, dh.MODP1536, "foo@bar.com", multiple)
+		})
+		if err != nil {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+/* Offline dictionary attack on simplified SRP
+ * Pose as the server and crack the client's password from its proof
+ */
+func c38() (actual, expected Result) {
+	input, _ := ioutil.ReadFile("input/38.txt")
+	words := strings.Split(strings.TrimSpace(string(input)), "\n")
+	password := words[r.Intn(len(words))]
+	client := srp.NewSimpleClient(dh.MODP1536, "foo@bar.com", password)
+
+	var cracked string
+	crackErr, loginErr := srpLogin(func(conn io.ReadWriter) (err error) {
+		_, cracked, err = srp.CrackSimple(conn, dh.MODP1536, stdBytes.NewReader(input))
+		return err
+	}, client.Login)
+	if crackErr != nil {
+		log.Fatal(crackErr)
+	}
+	if loginErr != nil {
+		log.Fatal(loginErr)
+	}
+
+	return cracked, password
+}
+
+// srpLogin runs serve and login against each other over a net.Pipe
+func srpLogin(serve, login func(io.ReadWriter) error) (serverErr, clientErr error) {
+	s, c := net.Pipe()
+	done := make(chan error)
+	go func() {
+		err := serve(s)
+		s.Close()
+		done <- err
+	}()
+	clientErr = login(c)
+	c.Close()
+	return <-done, clientErr
 }
 
 func equal(actual, expected Result) bool {
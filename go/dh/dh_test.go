@@ -0,0 +1,130 @@
+package dh
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	for bits, g := range map[int]*Group{
+		1536: MODP1536,
+		2048: MODP2048,
+		3072: MODP3072,
+		4096: MODP4096,
+		6144: MODP6144,
+		8192: MODP8192,
+	} {
+		if g.P.BitLen() != bits {
+			t.Errorf("expected a %d-bit prime, got %d bits", bits, g.P.BitLen())
+		}
+		if g.G.Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("%d: expected generator 2, got %v", bits, g.G)
+		}
+		if bits > 2048 && testing.Short() {
+			continue
+		}
+
+		// Each prime is a safe prime
+		q := new(big.Int).Rsh(g.P, 1)
+		if !g.P.ProbablyPrime(1) || !q.ProbablyPrime(1) {
+			t.Errorf("%d: expected a safe prime", bits)
+		}
+	}
+}
+
+func TestShared(t *testing.T) {
+	small := &Group{big.NewInt(37), big.NewInt(5)}
+	for _, g := range []*Group{small, MODP1536} {
+		a, err := GenerateKey(g, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := GenerateKey(g, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Shared(b.Public).Cmp(b.Shared(a.Public)) != 0 {
+			t.Errorf("p=%v: expected both sides to share a secret", g.P)
+		}
+	}
+}
+
+func TestValidPublicKey(t *testing.T) {
+	p := MODP1536.P
+	for _, y := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(p, big.NewInt(1)),
+		p,
+	} {
+		if MODP1536.ValidPublicKey(y) {
+			t.Errorf("expected %v to be rejected", y)
+		}
+	}
+
+	key, err := GenerateKey(MODP1536, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !MODP1536.ValidPublicKey(key.Public) {
+		t.Error("expected a generated public key to be valid")
+	}
+}
+
+var messages = [][]byte{
+	[]byte("YELLOW SUBMARINE"),
+	[]byte("We all live in a yellow submarine"),
+}
+
+func TestSimulate(t *testing.T) {
+	echoes, read, err := Simulate(MODP1536, messages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 0 {
+		t.Errorf("expected nothing read without a MITM, got %q", read)
+	}
+	checkEchoes(t, echoes)
+}
+
+func TestMITM(t *testing.T) {
+	one := func(*big.Int) *big.Int { return big.NewInt(1) }
+	p := func(p *big.Int) *big.Int { return p }
+	pMinusOne := func(p *big.Int) *big.Int { return new(big.Int).Sub(p, big.NewInt(1)) }
+
+	for name, m := range map[string]*MITM{
+		"key fixing": KeyFixing(),
+		"g = 1":      MaliciousGenerator(one),
+		"g = p":      MaliciousGenerator(p),
+		"g = p-1":    MaliciousGenerator(pMinusOne),
+	} {
+		echoes, read, err := Simulate(MODP1536, messages, m)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		checkEchoes(t, echoes)
+
+		// M reads each message on the way to B and again on the way back
+		if len(read) != 2*len(messages) {
+			t.Errorf("%s: expected %d messages read, got %d", name, 2*len(messages), len(read))
+			continue
+		}
+		for i, m := range messages {
+			if string(read[2*i]) != string(m) || string(read[2*i+1]) != string(m) {
+				t.Errorf("%s: expected to read %q twice, got %q", name, m, read[2*i:2*i+2])
+			}
+		}
+	}
+}
+
+func checkEchoes(t *testing.T, echoes [][]byte) {
+	if len(echoes) != len(messages) {
+		t.Fatalf("expected %d echoes, got %d", len(messages), len(echoes))
+	}
+	for i, m := range messages {
+		if string(echoes[i]) != string(m) {
+			t.Errorf("expected echo %q, got %q", m, echoes[i])
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package dh
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/taravancil/cryptopals/blocks"
+	"github.com/taravancil/cryptopals/bytes"
+	"github.com/taravancil/cryptopals/crypto"
+)
+
+// The key exchange goes:
+//
+//	A -> B  Negotiate{p, g}
+//	B -> A  Ack
+//	A -> B  PublicKey{A}
+//	B -> A  PublicKey{B}
+//
+// after which A sends Ciphertexts under the session key and B echoes each
+// one back under a fresh IV.
+
+// Message is anything the parties send each other
+type Message interface{}
+
+// Negotiate is A proposing a group
+type Negotiate struct {
+	P, G *big.Int
+}
+
+// Ack is B accepting the group
+type Ack struct{}
+
+// PublicKey carries a party's public key
+type PublicKey struct {
+	Y *big.Int
+}
+
+// Ciphertext is a message under AES-CBC with the session key
+type Ciphertext struct {
+	Data, IV []byte
+}
+
+// Conn is one end of an in-process link
+type Conn struct {
+	send       chan<- Message
+	recv       <-chan Message
+	self, peer *closer
+}
+
+type closer struct {
+	once sync.Once
+	done chan struct{}
+}
+
+// Pipe returns the two ends of a link. A send blocks until the other end
+// receives it or closes.
+func Pipe() (*Conn, *Conn) {
+	ab, ba := make(chan Message), make(chan Message)
+	a, b := &closer{done: make(chan struct{})}, &closer{done: make(chan struct{})}
+	return &Conn{ab, ba, a, b}, &Conn{ba, ab, b, a}
+}
+
+// Send sends m to the other end
+func (c *Conn) Send(m Message) error {
+	select {
+	case c.send <- m:
+		return nil
+	case <-c.peer.done:
+		return io.ErrClosedPipe
+	case <-c.self.done:
+		return io.ErrClosedPipe
+	}
+}
+
+// Receive waits for the next message from the other end. It returns io.EOF
+// once the other end has closed.
+func (c *Conn) Receive() (Message, error) {
+	select {
+	case m := <-c.recv:
+		return m, nil
+	case <-c.peer.done:
+		return nil, io.EOF
+	case <-c.self.done:
+		return nil, io.ErrClosedPipe
+	}
+}
+
+// Close closes this end of the link
+func (c *Conn) Close() {
+	c.self.once.Do(func() { close(c.self.done) })
+}
+
+// RunA proposes group, agrees a session key with whoever answers, sends
+// each of messages encrypted under it and returns the echoes it gets back
+func RunA(conn *Conn, group *Group, messages [][]byte) ([][]byte, error) {
+	defer conn.Close()
+
+	if err := conn.Send(&Negotiate{group.P, group.G}); err != nil {
+		return nil, err
+	}
+	if _, err := receiveAck(conn); err != nil {
+		return nil, err
+	}
+
+	key, err := GenerateKey(group, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Send(&PublicKey{key.Public}); err != nil {
+		return nil, err
+	}
+	peer, err := receivePublicKey(conn)
+	if err != nil {
+		return nil, err
+	}
+	session := SessionKey(key.Shared(peer.Y))
+
+	var echoes [][]byte
+	for _, m := range messages {
+		c, err := encrypt(session, m)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.Send(c); err != nil {
+			return nil, err
+		}
+		reply, err := receiveCiphertext(conn)
+		if err != nil {
+			return nil, err
+		}
+		echo, err := decrypt(session, reply)
+		if err != nil {
+			return nil, err
+		}
+		echoes = append(echoes, echo)
+	}
+	return echoes, nil
+}
+
+// RunB accepts whatever group it's offered and echoes back every message it
+// receives under the agreed session key, until the other end closes
+func RunB(conn *Conn) error {
+	defer conn.Close()
+
+	negotiate, err := receiveNegotiate(conn)
+	if err != nil {
+		return err
+	}
+	group := &Group{negotiate.P, negotiate.G}
+	if err := conn.Send(&Ack{}); err != nil {
+		return err
+	}
+
+	peer, err := receivePublicKey(conn)
+	if err != nil {
+		return err
+	}
+	key, err := GenerateKey(group, nil)
+	if err != nil {
+		return err
+	}
+	if err := conn.Send(&PublicKey{key.Public}); err != nil {
+		return err
+	}
+	session := SessionKey(key.Shared(peer.Y))
+
+	for {
+		c, err := receiveCiphertext(conn)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m, err := decrypt(session, c)
+		if err != nil {
+			return err
+		}
+		echo, err := encrypt(session, m)
+		if err != nil {
+			return err
+		}
+		if err := conn.Send(echo); err != nil {
+			return err
+		}
+	}
+}
+
+// MITM sits between A and B, rewriting the key exchange so it can predict
+// both session keys, then reading and re-encrypting every message. Make one
+// with KeyFixing or MaliciousGenerator.
+type MITM struct {
+	// generator, if set, replaces the generator A proposes before B sees it
+	generator func(p *big.Int) *big.Int
+
+	// toB and toA, if set, replace the public keys sent each way. They're
+	// given the group B agreed to.
+	toB, toA func(g *Group) *big.Int
+
+	// secrets returns the shared secrets A might hold, most likely first,
+	// and the one B holds, from B's group and the public keys A and B were
+	// sent
+	secrets func(g *Group, toA, toB *big.Int) ([]*big.Int, *big.Int)
+}
+
+// KeyFixing replaces both public keys with p, so both sides compute the
+// shared secret p^x mod p = 0
+func KeyFixing() *MITM {
+	modulus := func(g *Group) *big.Int { return g.P }
+	return &MITM{
+		toB: modulus,
+		toA: modulus,
+		secrets: func(*Group, *big.Int, *big.Int) ([]*big.Int, *big.Int) {
+			return []*big.Int{big.NewInt(0)}, big.NewInt(0)
+		},
+	}
+}
+
+// MaliciousGenerator offers B generator(p) in place of A's generator, and
+// sends B that value as A's public key too. B's secret is then its own
+// public key, which for a generator of 1, p or p-1 is 1, 0, or 1 or p-1.
+// A's secret is that raised to A's exponent, so it's the same value or,
+// for p-1, possibly 1.
+func MaliciousGenerator(generator func(p *big.Int) *big.Int) *MITM {
+	return &MITM{
+		generator: generator,
+		toB:       func(g *Group) *big.Int { return g.G },
+		secrets: func(g *Group, toA, toB *big.Int) ([]*big.Int, *big.Int) {
+			y := new(big.Int).Mod(toA, g.P)
+			forA := []*big.Int{y}
+			if y.Cmp(big.NewInt(1)) != 0 {
+				forA = append(forA, big.NewInt(1))
+			}
+			return forA, y
+		},
+	}
+}
+
+// Run relays between a, the end facing A, and b, the end facing B, until A
+// closes. It returns the plaintexts it read in order, alternating A's
+// messages and B's echoes.
+func (m *MITM) Run(a, b *Conn) ([][]byte, error) {
+	defer a.Close()
+	defer b.Close()
+
+	negotiate, err := receiveNegotiate(a)
+	if err != nil {
+		return nil, err
+	}
+	group := &Group{negotiate.P, negotiate.G}
+	if m.generator != nil {
+		group.G = m.generator(group.P)
+	}
+	if err := b.Send(&Negotiate{group.P, group.G}); err != nil {
+		return nil, err
+	}
+	ack, err := receiveAck(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Send(ack); err != nil {
+		return nil, err
+	}
+
+	fromA, err := receivePublicKey(a)
+	if err != nil {
+		return nil, err
+	}
+	toB := fromA.Y
+	if m.toB != nil {
+		toB = m.toB(group)
+	}
+	if err := b.Send(&PublicKey{toB}); err != nil {
+		return nil, err
+	}
+	fromB, err := receivePublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	toA := fromB.Y
+	if m.toA != nil {
+		toA = m.toA(group)
+	}
+	if err := a.Send(&PublicKey{toA}); err != nil {
+		return nil, err
+	}
+
+	candidates, secretB := m.secrets(group, toA, toB)
+	sessionB := SessionKey(secretB)
+	var sessionA []byte
+
+	var read [][]byte
+	for {
+		c, err := receiveCiphertext(a)
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if sessionA == nil {
+			if sessionA, err = pickSession(candidates, c); err != nil {
+				return nil, err
+			}
+		}
+
+		plaintext, err := relay(c, sessionA, sessionB, b)
+		if err != nil {
+			return nil, err
+		}
+		read = append(read, plaintext)
+
+		reply, err := receiveCiphertext(b)
+		if err != nil {
+			return nil, err
+		}
+		echo, err := relay(reply, sessionB, sessionA, a)
+		if err != nil {
+			return nil, err
+		}
+		read = append(read, echo)
+	}
+}
+
+// pickSession returns the session key for whichever of secrets decrypts c
+// with the longest valid padding. A wrong key only gives valid padding by
+// chance, and then almost always a single byte.
+func pickSession(secrets []*big.Int, c *Ciphertext) ([]byte, error) {
+	var best []byte
+	longest := 0
+	for _, s := range secrets {
+		key := SessionKey(s)
+		padded, err := crypto.CbcDecrypt(c.Data, key, c.IV)
+		if err != nil {
+			return nil, err
+		}
+		if valid, n, _ := blocks.ValidPkcs7(padded); valid && n > longest {
+			best, longest = key, n
+		}
+	}
+	if best == nil {
+		return nil, errors.New("dh: none of the possible secrets decrypts the message")
+	}
+	return best, nil
+}
+
+// relay decrypts c under one session key, re-encrypts it under the other
+// and sends it to conn
+func relay(c *Ciphertext, from, to []byte, conn *Conn) ([]byte, error) {
+	plaintext, err := decrypt(from, c)
+	if err != nil {
+		return nil, err
+	}
+	forwarded, err := encrypt(to, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, conn.Send(forwarded)
+}
+
+// Simulate runs A sending messages to B, with m in the middle unless it's
+// nil. It returns the echoes A got back and what m read.
+func Simulate(group *Group, messages [][]byte, m *MITM) (echoes, read [][]byte, err error) {
+	a, toA := Pipe()
+	toB := toA
+	var wg sync.WaitGroup
+	var bErr, mErr error
+
+	if m != nil {
+		var fromM *Conn
+		fromM, toB = Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			read, mErr = m.Run(toA, fromM)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bErr = RunB(toB)
+	}()
+
+	echoes, err = RunA(a, group, messages)
+	wg.Wait()
+	for _, e := range []error{err, mErr, bErr} {
+		if e != nil {
+			return nil, nil, e
+		}
+	}
+	return echoes, read, nil
+}
+
+func encrypt(key, plaintext []byte) (*Ciphertext, error) {
+	iv, err := bytes.Random(aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.CbcEncrypt(append([]byte{}, plaintext...), key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &Ciphertext{data, iv}, nil
+}
+
+func decrypt(key []byte, c *Ciphertext) ([]byte, error) {
+	padded, err := crypto.CbcDecrypt(c.Data, key, c.IV)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.StripIfValidPkcs7(padded)
+}
+
+func receiveNegotiate(conn *Conn) (*Negotiate, error) {
+	m, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := m.(*Negotiate); ok {
+		return n, nil
+	}
+	return nil, unexpected(&Negotiate{}, m)
+}
+
+func receiveAck(conn *Conn) (*Ack, error) {
+	m, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := m.(*Ack); ok {
+		return a, nil
+	}
+	return nil, unexpected(&Ack{}, m)
+}
+
+func receivePublicKey(conn *Conn) (*PublicKey, error) {
+	m, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := m.(*PublicKey); ok {
+		return p, nil
+	}
+	return nil, unexpected(&PublicKey{}, m)
+}
+
+func receiveCiphertext(conn *Conn) (*Ciphertext, error) {
+	m, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := m.(*Ciphertext); ok {
+		return c, nil
+	}
+	return nil, unexpected(&Ciphertext{}, m)
+}
+
+func unexpected(want, got Message) error {
+	return fmt.Errorf("dh: expected %T, got %T", want, got)
+}
@@ -0,0 +1,90 @@
+// Package md4 is a pure-Go MD4 whose internal state can be set, for length
+// extension attacks on secret-prefix MACs. MD4 is broken and only here to
+// be attacked.
+package md4
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+
+	"github.com/taravancil/cryptopals/mdhash"
+)
+
+// Size is the size of a MD4 digest in bytes
+const Size = 16
+
+// BlockSize is the block size of MD4 in bytes
+const BlockSize = mdhash.BlockSize
+
+var spec = &mdhash.Spec{
+	Initial: []uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476},
+	Order:   binary.LittleEndian,
+	Block:   block,
+}
+
+// New returns a MD4 hash.Hash
+func New() hash.Hash {
+	return mdhash.New(spec)
+}
+
+// NewFromState returns a MD4 hash.Hash that carries on from the given
+// digest as if it had already hashed length bytes. length must include the
+// padding hashed before the digest was produced, so it is a multiple of
+// BlockSize. Reset returns to this state rather than the initial one.
+func NewFromState(sum []byte, length uint64) (hash.Hash, error) {
+	return mdhash.NewFromState(spec, sum, length)
+}
+
+// Sum returns the MD4 digest of data
+func Sum(data []byte) []byte {
+	h := New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Padding returns the padding MD4 appends to a message of messageLen
+// bytes: a 1 bit, zeros up to 8 bytes short of a block, then the message
+// length in bits, little-endian
+func Padding(messageLen uint64) []byte {
+	return spec.Padding(messageLen)
+}
+
+// The order message words are used in and the rotations of rounds 2 and 3
+var (
+	round2Order = [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+	round3Order = [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+	round1Shift = [4]int{3, 7, 11, 19}
+	round2Shift = [4]int{3, 5, 9, 13}
+	round3Shift = [4]int{3, 9, 11, 15}
+)
+
+// block runs the compression function over one block
+func block(state []uint32, p []byte) {
+	var x [16]uint32
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(p[4*i:])
+	}
+
+	a, b, c, dd := state[0], state[1], state[2], state[3]
+	for i := 0; i < 16; i++ {
+		f := b&c | ^b&dd
+		t := bits.RotateLeft32(a+f+x[i], round1Shift[i%4])
+		a, b, c, dd = dd, t, b, c
+	}
+	for i := 0; i < 16; i++ {
+		g := b&c | b&dd | c&dd
+		t := bits.RotateLeft32(a+g+x[round2Order[i]]+0x5a827999, round2Shift[i%4])
+		a, b, c, dd = dd, t, b, c
+	}
+	for i := 0; i < 16; i++ {
+		h := b ^ c ^ dd
+		t := bits.RotateLeft32(a+h+x[round3Order[i]]+0x6ed9eba1, round3Shift[i%4])
+		a, b, c, dd = dd, t, b, c
+	}
+
+	state[0] += a
+	state[1] += b
+	state[2] += c
+	state[3] += dd
+}
@@ -0,0 +1,90 @@
+package md4
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 1320 test suite
+var vectors = map[string]string{
+	"":                           "31d6cfe0d16ae931b73c59d7e0c089c0",
+	"a":                          "bde52cb31de33e46245e05fbdbd6fb24",
+	"abc":                        "a448017aaf21d8525fc10ae87aa6729d",
+	"message digest":             "d9130a8164549fe818874806e1c7014b",
+	"abcdefghijklmnopqrstuvwxyz": "d79e1c308aa5bbcdeea8ed63df412da9",
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789":                   "043f8582f241db351ce627e153e7f0e4",
+	"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+}
+
+func TestSum(t *testing.T) {
+	for message, expected := range vectors {
+		if actual := hex.EncodeToString(Sum([]byte(message))); actual != expected {
+			t.Errorf("%q: expected %s, got %s", message, expected, actual)
+		}
+	}
+}
+
+func TestWritePieces(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.Read(data)
+	expected := Sum(data)
+
+	h := New()
+	for p := data; len(p) > 0; {
+		n := 1 + len(p)%97
+		if n > len(p) {
+			n = len(p)
+		}
+		h.Write(p[:n])
+		p = p[n:]
+
+		// Sum doesn't disturb the state
+		h.Sum(nil)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		t.Errorf("expected %x, got %x", expected, actual)
+	}
+
+	h.Reset()
+	h.Write(data)
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		t.Errorf("after Reset: expected %x, got %x", expected, actual)
+	}
+}
+
+func TestNewFromState(t *testing.T) {
+	message := []byte("comment1=cooking%20MCs;userdata=foo")
+	suffix := []byte(";admin=true")
+
+	// Hashing the padded message then the suffix continues the state left
+	// by the digest of the message
+	padded := append(append([]byte{}, message...), Padding(uint64(len(message)))...)
+	expected := Sum(append(append([]byte{}, padded...), suffix...))
+
+	h, err := NewFromState(Sum(message), uint64(len(padded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write(suffix)
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		t.Errorf("expected %x, got %x", expected, actual)
+	}
+
+	if _, err := NewFromState(make([]byte, Size), 10); err == nil {
+		t.Error("should fail given a length that isn't whole blocks")
+	}
+	if _, err := NewFromState(make([]byte, 20), 64); err == nil {
+		t.Error("should fail given a digest of the wrong size")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	for n := uint64(0); n < 200; n++ {
+		pad := Padding(n)
+		if (n+uint64(len(pad)))%BlockSize != 0 || pad[0] != 0x80 || len(pad) > BlockSize+8 {
+			t.Fatalf("length %d: bad padding %x", n, pad)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package mdhash is the Merkle–Damgård framing the sha1 and md4 packages
+// share: buffering input into blocks, length padding, and carrying on from
+// a given digest for length extension. Each hash plugs in its compression
+// function and the byte order of its words and length.
+package mdhash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// BlockSize is the block size in bytes of every hash built on a Spec
+const BlockSize = 64
+
+// maxWords is the most words of state a Spec can have
+const maxWords = 8
+
+// Spec describes a Merkle–Damgård hash over 64-byte blocks and 32-bit words
+type Spec struct {
+	// Initial is the state before any input, at most 8 words. Its length
+	// sets the digest size.
+	Initial []uint32
+
+	// Order is the byte order of the digest's words and the padded length
+	Order binary.ByteOrder
+
+	// Block runs the compression function over one block, updating state
+	Block func(state []uint32, p []byte)
+}
+
+// Size returns the digest size in bytes
+func (s *Spec) Size() int {
+	return 4 * len(s.Initial)
+}
+
+// Padding returns the padding appended to a message of messageLen bytes: a
+// 1 bit, zeros up to 8 bytes short of a block, then the message length in
+// bits in the spec's byte order
+func (s *Spec) Padding(messageLen uint64) []byte {
+	n := BlockSize - (messageLen+8)%BlockSize
+	pad := make([]byte, n+8)
+	pad[0] = 0x80
+	s.Order.PutUint64(pad[n:], messageLen*8)
+	return pad
+}
+
+type digest struct {
+	spec *Spec
+	h    [maxWords]uint32
+	buf  [BlockSize]byte
+	nbuf int
+	len  uint64
+
+	// The state and length Reset returns to
+	startH   [maxWords]uint32
+	startLen uint64
+}
+
+// New returns a hash.Hash for spec
+func New(spec *Spec) hash.Hash {
+	d := &digest{spec: spec}
+	copy(d.startH[:], spec.Initial)
+	d.Reset()
+	return d
+}
+
+// NewFromState returns a hash.Hash for spec that carries on from the given
+// digest as if it had already hashed length bytes. length must include the
+// padding hashed before the digest was produced, so it is a multiple of
+// BlockSize. Reset returns to this state rather than the initial one.
+func NewFromState(spec *Spec, sum []byte, length uint64) (hash.Hash, error) {
+	if len(sum) != spec.Size() {
+		return nil, fmt.Errorf("digest must be %d bytes", spec.Size())
+	}
+	if length%BlockSize != 0 {
+		return nil, fmt.Errorf("length must be a multiple of %d", BlockSize)
+	}
+
+	d := &digest{spec: spec, startLen: length}
+	for i := range spec.Initial {
+		d.startH[i] = spec.Order.Uint32(sum[4*i:])
+	}
+	d.Reset()
+	return d, nil
+}
+
+func (d *digest) Reset() {
+	d.h = d.startH
+	d.nbuf = 0
+	d.len = d.startLen
+}
+
+func (d *digest) Size() int {
+	return d.spec.Size()
+}
+
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	state := d.h[:len(d.spec.Initial)]
+
+	if d.nbuf > 0 {
+		k := copy(d.buf[d.nbuf:], p)
+		d.nbuf += k
+		p = p[k:]
+		if d.nbuf < BlockSize {
+			return n, nil
+		}
+		d.spec.Block(state, d.buf[:])
+		d.nbuf = 0
+	}
+	for len(p) >= BlockSize {
+		d.spec.Block(state, p[:BlockSize])
+		p = p[BlockSize:]
+	}
+	d.nbuf = copy(d.buf[:], p)
+	return n, nil
+}
+
+// Sum appends the digest to b without changing the hash's state
+func (d *digest) Sum(b []byte) []byte {
+	c := *d
+	c.Write(d.spec.Padding(d.len))
+
+	sum := make([]byte, d.spec.Size())
+	for i, x := range c.h[:len(d.spec.Initial)] {
+		d.spec.Order.PutUint32(sum[4*i:], x)
+	}
+	return append(b, sum...)
+}
@@ -0,0 +1,77 @@
+package mdhash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// toy folds each block into the state so the framing can be tested without
+// a real hash
+var toy = &Spec{
+	Initial: []uint32{1, 2, 3},
+	Order:   binary.LittleEndian,
+	Block: func(state []uint32, p []byte) {
+		for i := 0; i < BlockSize; i += 4 {
+			state[i/4%len(state)] = state[i/4%len(state)]*31 + binary.LittleEndian.Uint32(p[i:])
+		}
+	},
+}
+
+func TestPadding(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		s := &Spec{Order: order}
+		for n := uint64(0); n < 200; n++ {
+			pad := s.Padding(n)
+			if (n+uint64(len(pad)))%BlockSize != 0 || pad[0] != 0x80 || len(pad) > BlockSize+8 {
+				t.Fatalf("%v, length %d: bad padding %x", order, n, pad)
+			}
+			if bits := order.Uint64(pad[len(pad)-8:]); bits != 8*n {
+				t.Fatalf("%v, length %d: padding encodes %d bits", order, n, bits)
+			}
+		}
+	}
+}
+
+func TestFraming(t *testing.T) {
+	data := bytes.Repeat([]byte("YELLOW SUBMARINE"), 20)
+	whole := New(toy)
+	whole.Write(data)
+	expected := whole.Sum(nil)
+	if len(expected) != 12 || whole.Size() != 12 {
+		t.Fatalf("expected a 12-byte digest, got %d", len(expected))
+	}
+
+	h := New(toy)
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		h.Write(data[i:end])
+		h.Sum(nil)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		t.Errorf("writing in pieces: expected %x, got %x", expected, actual)
+	}
+
+	// Carrying on from a digest is the same as hashing the padded message
+	padded := append(append([]byte{}, data...), toy.Padding(uint64(len(data)))...)
+	cont, err := NewFromState(toy, expected, uint64(len(padded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cont.Write([]byte("more"))
+	h.Reset()
+	h.Write(append(padded, "more"...))
+	if !bytes.Equal(cont.Sum(nil), h.Sum(nil)) {
+		t.Error("NewFromState didn't carry on from the digest")
+	}
+
+	if _, err := NewFromState(toy, expected[1:], 64); err == nil {
+		t.Error("should fail given a digest of the wrong size")
+	}
+	if _, err := NewFromState(toy, expected, 10); err == nil {
+		t.Error("should fail given a length that isn't whole blocks")
+	}
+}
@@ -83,12 +83,39 @@ func ProcessComment(s string) string {
 	return prependStr + s + appendStr
 }
 
+// HasAdmin decrypts a comment encrypted under AES CBC and reports whether
+// it contains admin=true
 func HasAdmin(ciphertext, key, iv []byte) bool {
 	decrypted, _ := crypto.CbcDecrypt(ciphertext, key, iv)
-	tuples := strings.Split(string(decrypted), ";")
+	return hasAdmin(decrypted)
+}
+
+// HasAdminCtr decrypts a comment encrypted under AES CTR (see crypto.Ctr)
+// and reports whether it contains admin=true
+func HasAdminCtr(ciphertext, key []byte, nonce uint64) bool {
+	stream, err := crypto.Ctr(nonce, key)
+	if err != nil {
+		return false
+	}
+	decrypted := make([]byte, len(ciphertext))
+	stream.XORKeyStream(decrypted, ciphertext)
+	return hasAdmin(decrypted)
+}
+
+// HasAdminMAC checks a cookie's MAC with v and reports whether the cookie
+// contains admin=true
+func HasAdminMAC(cookie, mac []byte, v crypto.MACVerifier) bool {
+	if !v.Verify(cookie, mac) {
+		return false
+	}
+	return hasAdmin(cookie)
+}
+
+func hasAdmin(comment []byte) bool {
+	tuples := strings.Split(string(comment), ";")
 	for _, val := range tuples {
 		s := strings.Split(val, "=")
-		if s[0] == "admin" && s[1] == "true" {
+		if len(s) == 2 && s[0] == "admin" && s[1] == "true" {
 			return true
 		}
 	}
@@ -1,8 +1,11 @@
 package profile
 
 import (
+	"crypto/sha256"
 	"strings"
 	"testing"
+
+	"github.com/taravancil/cryptopals/crypto"
 )
 
 func TestQuoteForbidden(t *testing.T) {
@@ -30,3 +33,43 @@ func TestNew(t *testing.T) {
 		t.Errorf("expected %s, got %s", expected, profile)
 	}
 }
+
+func TestHasAdmin(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	iv := make([]byte, 16)
+
+	for comment, admin := range map[string]bool{
+		ProcessComment(";admin=true;"):        false,
+		"comment1=cooking;admin=true;x":       true,
+		"comment1=cooking;admin;x=y=z;admin=": false,
+	} {
+		ciphertext, _ := crypto.CbcEncrypt([]byte(comment), key, iv)
+		if HasAdmin(ciphertext, key, iv) != admin {
+			t.Errorf("CBC %q: expected %v", comment, admin)
+		}
+
+		stream, _ := crypto.Ctr(7, key)
+		ciphertext = make([]byte, len(comment))
+		stream.XORKeyStream(ciphertext, []byte(comment))
+		if HasAdminCtr(ciphertext, key, 7) != admin {
+			t.Errorf("CTR %q: expected %v", comment, admin)
+		}
+	}
+}
+
+func TestHasAdminMAC(t *testing.T) {
+	mac := crypto.NewHMAC(sha256.New, []byte("YELLOW SUBMARINE"))
+	cookie := []byte(New("foo@bar.com") + ";admin=true")
+
+	if !HasAdminMAC(cookie, mac.Sign(cookie), mac) {
+		t.Error("expected a signed admin cookie to pass")
+	}
+	if HasAdminMAC(cookie, mac.Sign([]byte(New("foo@bar.com"))), mac) {
+		t.Error("expected a cookie with the wrong MAC to fail")
+	}
+
+	cookie = []byte(New("foo@bar.com"))
+	if HasAdminMAC(cookie, mac.Sign(cookie), mac) {
+		t.Error("expected a signed cookie without admin=true to fail")
+	}
+}
@@ -0,0 +1,396 @@
+// Package rijndael is a from-scratch AES for studying and attacking the
+// cipher. It exposes each round operation and the key schedule, can run a
+// reduced number of rounds, and reports every intermediate state. It is
+// slow and not constant time, so use crypto/aes for anything real.
+package rijndael
+
+import (
+	"fmt"
+)
+
+// BlockSize is the AES block size in bytes
+const BlockSize = 16
+
+// State is the 4x4 AES state. Bytes are stored column by column as in
+// FIPS-197, so row r of column c is State[r+4*c], and a block is loaded
+// into the state without reordering.
+type State [BlockSize]byte
+
+// The S-box and its inverse, computed by init
+var sbox, invSbox [256]byte
+
+func init() {
+	for i := 0; i < 256; i++ {
+		// Multiplicative inverse in GF(2^8), with 0 mapping to 0
+		var inv byte
+		if i != 0 {
+			inv = gexp(byte(i), 254)
+		}
+
+		// Affine transformation
+		s := inv ^ rotl8(inv, 1) ^ rotl8(inv, 2) ^ rotl8(inv, 3) ^ rotl8(inv, 4) ^ 0x63
+		sbox[i] = s
+		invSbox[s] = byte(i)
+	}
+}
+
+func rotl8(b byte, n uint) byte {
+	return b<<n | b>>(8-n)
+}
+
+// xtime multiplies b by x in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1
+func xtime(b byte) byte {
+	if b&0x80 != 0 {
+		return b<<1 ^ 0x1b
+	}
+	return b << 1
+}
+
+// gmul multiplies a and b in GF(2^8)
+func gmul(a, b byte) byte {
+	var p byte
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		a = xtime(a)
+		b >>= 1
+	}
+	return p
+}
+
+// gexp raises b to the nth power in GF(2^8)
+func gexp(b byte, n int) byte {
+	p := byte(1)
+	for ; n > 0; n-- {
+		p = gmul(p, b)
+	}
+	return p
+}
+
+// SBox returns the AES S-box applied to b
+func SBox(b byte) byte {
+	return sbox[b]
+}
+
+// InvSBox returns the inverse AES S-box applied to b
+func InvSBox(b byte) byte {
+	return invSbox[b]
+}
+
+// SubBytes replaces every byte of s with its S-box value
+func SubBytes(s *State) {
+	for i := range s {
+		s[i] = sbox[s[i]]
+	}
+}
+
+// InvSubBytes undoes SubBytes
+func InvSubBytes(s *State) {
+	for i := range s {
+		s[i] = invSbox[s[i]]
+	}
+}
+
+// ShiftRows rotates row r of s left by r columns
+func ShiftRows(s *State) {
+	t := *s
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			s[r+4*c] = t[r+4*((c+r)%4)]
+		}
+	}
+}
+
+// InvShiftRows undoes ShiftRows
+func InvShiftRows(s *State) {
+	t := *s
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			s[r+4*((c+r)%4)] = t[r+4*c]
+		}
+	}
+}
+
+// MixColumns multiplies each column of s by the fixed polynomial
+// 3x^3 + x^2 + x + 2
+func MixColumns(s *State) {
+	for c := 0; c < 4; c++ {
+		a0, a1, a2, a3 := s[4*c], s[4*c+1], s[4*c+2], s[4*c+3]
+		s[4*c] = gmul(a0, 2) ^ gmul(a1, 3) ^ a2 ^ a3
+		s[4*c+1] = a0 ^ gmul(a1, 2) ^ gmul(a2, 3) ^ a3
+		s[4*c+2] = a0 ^ a1 ^ gmul(a2, 2) ^ gmul(a3, 3)
+		s[4*c+3] = gmul(a0, 3) ^ a1 ^ a2 ^ gmul(a3, 2)
+	}
+}
+
+// InvMixColumns undoes MixColumns
+func InvMixColumns(s *State) {
+	for c := 0; c < 4; c++ {
+		a0, a1, a2, a3 := s[4*c], s[4*c+1], s[4*c+2], s[4*c+3]
+		s[4*c] = gmul(a0, 14) ^ gmul(a1, 11) ^ gmul(a2, 13) ^ gmul(a3, 9)
+		s[4*c+1] = gmul(a0, 9) ^ gmul(a1, 14) ^ gmul(a2, 11) ^ gmul(a3, 13)
+		s[4*c+2] = gmul(a0, 13) ^ gmul(a1, 9) ^ gmul(a2, 14) ^ gmul(a3, 11)
+		s[4*c+3] = gmul(a0, 11) ^ gmul(a1, 13) ^ gmul(a2, 9) ^ gmul(a3, 14)
+	}
+}
+
+// AddRoundKey XORs the round key k into s. It is its own inverse.
+func AddRoundKey(s *State, k State) {
+	for i := range s {
+		s[i] ^= k[i]
+	}
+}
+
+// Rounds returns the number of rounds full AES uses with a key of keyLen
+// bytes, or 0 if keyLen isn't a valid AES key size
+func Rounds(keyLen int) int {
+	switch keyLen {
+	case 16:
+		return 10
+	case 24:
+		return 12
+	case 32:
+		return 14
+	}
+	return 0
+}
+
+// ExpandKey runs the AES key schedule and returns the round keys for the
+// full number of rounds: one more than the number of rounds, the first
+// being used before round 1.
+func ExpandKey(key []byte) ([]State, error) {
+	rounds := Rounds(len(key))
+	if rounds == 0 {
+		return nil, fmt.Errorf("invalid AES key size %d", len(key))
+	}
+
+	// The schedule works on 4-byte words, nk of which come from the key
+	nk := len(key) / 4
+	words := make([][4]byte, 4*(rounds+1))
+	for i := 0; i < nk; i++ {
+		copy(words[i][:], key[4*i:])
+	}
+
+	rcon := byte(1)
+	for i := nk; i < len(words); i++ {
+		w := words[i-1]
+		switch {
+		case i%nk == 0:
+			// RotWord, SubWord and the round constant
+			w = [4]byte{sbox[w[1]] ^ rcon, sbox[w[2]], sbox[w[3]], sbox[w[0]]}
+			rcon = xtime(rcon)
+		case nk > 6 && i%nk == 4:
+			w = [4]byte{sbox[w[0]], sbox[w[1]], sbox[w[2]], sbox[w[3]]}
+		}
+		for j := range w {
+			words[i][j] = words[i-nk][j] ^ w[j]
+		}
+	}
+
+	keys := make([]State, rounds+1)
+	for i := range keys {
+		for j := 0; j < 4; j++ {
+			copy(keys[i][4*j:], words[4*i+j][:])
+		}
+	}
+	return keys, nil
+}
+
+// InvertKeySchedule runs the AES-128 key schedule backwards from the key of
+// the given round and returns the original key. Every AES-128 round key
+// determines the key, so recovering any one of them breaks the cipher.
+func InvertKeySchedule(roundKey State, round int) ([]byte, error) {
+	if round < 0 || round > Rounds(16) {
+		return nil, fmt.Errorf("invalid AES-128 round %d", round)
+	}
+
+	k := roundKey
+	for ; round > 0; round-- {
+		// The round constant used to derive this round key is x^(round-1)
+		rcon := gexp(2, round-1)
+
+		// Words 1 to 3 are each the previous word XORed with the word one
+		// round key earlier
+		for i := 15; i >= 4; i-- {
+			k[i] ^= k[i-4]
+		}
+		k[0] ^= sbox[k[13]] ^ rcon
+		k[1] ^= sbox[k[14]]
+		k[2] ^= sbox[k[15]]
+		k[3] ^= sbox[k[12]]
+	}
+	return append([]byte{}, k[:]...), nil
+}
+
+// Step identifies an operation within an encryption or decryption
+type Step int
+
+// The steps reported to a Hook
+const (
+	StepAddRoundKey Step = iota
+	StepSubBytes
+	StepShiftRows
+	StepMixColumns
+	StepInvSubBytes
+	StepInvShiftRows
+	StepInvMixColumns
+)
+
+func (s Step) String() string {
+	switch s {
+	case StepAddRoundKey:
+		return "AddRoundKey"
+	case StepSubBytes:
+		return "SubBytes"
+	case StepShiftRows:
+		return "ShiftRows"
+	case StepMixColumns:
+		return "MixColumns"
+	case StepInvSubBytes:
+		return "InvSubBytes"
+	case StepInvShiftRows:
+		return "InvShiftRows"
+	case StepInvMixColumns:
+		return "InvMixColumns"
+	}
+	return fmt.Sprintf("Step(%d)", int(s))
+}
+
+// A Hook is called with the state after each step. round is 0 for the
+// initial AddRoundKey, then counts up from 1 when encrypting and down to 1
+// when decrypting. The hook may change the state, e.g. to inject a fault.
+type Hook func(round int, step Step, s *State)
+
+// Cipher is AES with a configurable number of rounds. It implements
+// cipher.Block.
+type Cipher struct {
+	roundKeys []State
+	rounds    int
+
+	// Hook, if set, sees every intermediate state
+	Hook Hook
+}
+
+// NewCipher returns full AES with a 16, 24 or 32-byte key
+func NewCipher(key []byte) (*Cipher, error) {
+	return NewCipherRounds(key, Rounds(len(key)))
+}
+
+// NewCipherRounds returns AES reduced to the given number of rounds. It
+// uses the first round keys of the normal key schedule, and its last round
+// skips MixColumns as the last round of full AES does.
+func NewCipherRounds(key []byte, rounds int) (*Cipher, error) {
+	keys, err := ExpandKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if rounds < 1 || rounds >= len(keys) {
+		return nil, fmt.Errorf("rounds must be between 1 and %d", len(keys)-1)
+	}
+	return &Cipher{roundKeys: keys[:rounds+1], rounds: rounds}, nil
+}
+
+// Rounds returns the number of rounds c runs
+func (c *Cipher) Rounds() int {
+	return c.rounds
+}
+
+// RoundKey returns the key added after round i, or before round 1 if i is 0
+func (c *Cipher) RoundKey(i int) State {
+	return c.roundKeys[i]
+}
+
+func (c *Cipher) BlockSize() int {
+	return BlockSize
+}
+
+func (c *Cipher) step(round int, step Step, s *State) {
+	if c.Hook != nil {
+		c.Hook(round, step, s)
+	}
+}
+
+// EncryptState encrypts s in place
+func (c *Cipher) EncryptState(s *State) {
+	AddRoundKey(s, c.roundKeys[0])
+	c.step(0, StepAddRoundKey, s)
+
+	for r := 1; r <= c.rounds; r++ {
+		SubBytes(s)
+		c.step(r, StepSubBytes, s)
+		ShiftRows(s)
+		c.step(r, StepShiftRows, s)
+		if r != c.rounds {
+			MixColumns(s)
+			c.step(r, StepMixColumns, s)
+		}
+		AddRoundKey(s, c.roundKeys[r])
+		c.step(r, StepAddRoundKey, s)
+	}
+}
+
+// DecryptState decrypts s in place
+func (c *Cipher) DecryptState(s *State) {
+	for r := c.rounds; r >= 1; r-- {
+		AddRoundKey(s, c.roundKeys[r])
+		c.step(r, StepAddRoundKey, s)
+		if r != c.rounds {
+			InvMixColumns(s)
+			c.step(r, StepInvMixColumns, s)
+		}
+		InvShiftRows(s)
+		c.step(r, StepInvShiftRows, s)
+		InvSubBytes(s)
+		c.step(r, StepInvSubBytes, s)
+	}
+
+	AddRoundKey(s, c.roundKeys[0])
+	c.step(0, StepAddRoundKey, s)
+}
+
+func (c *Cipher) Encrypt(dst, src []byte) {
+	if len(src) < BlockSize || len(dst) < BlockSize {
+		panic("rijndael: input not full block")
+	}
+	var s State
+	copy(s[:], src)
+	c.EncryptState(&s)
+	copy(dst, s[:])
+}
+
+func (c *Cipher) Decrypt(dst, src []byte) {
+	if len(src) < BlockSize || len(dst) < BlockSize {
+		panic("rijndael: input not full block")
+	}
+	var s State
+	copy(s[:], src)
+	c.DecryptState(&s)
+	copy(dst, s[:])
+}
+
+// Snapshot is an intermediate state recorded by Trace
+type Snapshot struct {
+	Round int
+	Step  Step
+	State State
+}
+
+// Trace encrypts one block and returns the state after every step, the
+// last snapshot being the ciphertext. It calls c's Hook as usual.
+func (c *Cipher) Trace(src []byte) []Snapshot {
+	var snapshots []Snapshot
+	hook := c.Hook
+	traced := *c
+	traced.Hook = func(round int, step Step, s *State) {
+		if hook != nil {
+			hook(round, step, s)
+		}
+		snapshots = append(snapshots, Snapshot{round, step, *s})
+	}
+
+	var s State
+	copy(s[:], src)
+	traced.EncryptState(&s)
+	return snapshots
+}
@@ -0,0 +1,208 @@
+package rijndael
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCompareWithStdlib(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		key := make([]byte, size)
+		for i := 0; i < 20; i++ {
+			rand.Read(key)
+			block := make([]byte, BlockSize)
+			rand.Read(block)
+
+			ours, err := NewCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			theirs, _ := aes.NewCipher(key)
+
+			expected := make([]byte, BlockSize)
+			actual := make([]byte, BlockSize)
+			theirs.Encrypt(expected, block)
+			ours.Encrypt(actual, block)
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("%d-byte key %x: expected %x, got %x", size, key, expected, actual)
+			}
+
+			ours.Decrypt(actual, actual)
+			if !bytes.Equal(actual, block) {
+				t.Fatalf("%d-byte key %x: decryption failed", size, key)
+			}
+		}
+	}
+
+	if _, err := NewCipher(make([]byte, 15)); err == nil {
+		t.Error("should fail given an invalid key size")
+	}
+}
+
+// FIPS-197 Appendix B
+func TestTrace(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	plaintext, _ := hex.DecodeString("3243f6a8885a308d313198a2e0370734")
+	c, _ := NewCipher(key)
+
+	snapshots := c.Trace(plaintext)
+	if len(snapshots) != 1+4*9+3 {
+		t.Fatalf("unexpected number of snapshots %d", len(snapshots))
+	}
+
+	expected := []struct {
+		i     int
+		round int
+		step  Step
+		state string
+	}{
+		{0, 0, StepAddRoundKey, "193de3bea0f4e22b9ac68d2ae9f84808"},
+		{1, 1, StepSubBytes, "d42711aee0bf98f1b8b45de51e415230"},
+		{2, 1, StepShiftRows, "d4bf5d30e0b452aeb84111f11e2798e5"},
+		{3, 1, StepMixColumns, "046681e5e0cb199a48f8d37a2806264c"},
+		{4, 1, StepAddRoundKey, "a49c7ff2689f352b6b5bea43026a5049"},
+		{len(snapshots) - 1, 10, StepAddRoundKey, "3925841d02dc09fbdc118597196a0b32"},
+	}
+	for _, e := range expected {
+		s := snapshots[e.i]
+		if s.Round != e.round || s.Step != e.step || hex.EncodeToString(s.State[:]) != e.state {
+			t.Errorf("snapshot %d: expected round %d %s %s, got round %d %s %x",
+				e.i, e.round, e.step, e.state, s.Round, s.Step, s.State)
+		}
+	}
+}
+
+func TestExpandKey(t *testing.T) {
+	// FIPS-197 Appendix A.1 and A.3, last round keys
+	vectors := map[string]string{
+		"2b7e151628aed2a6abf7158809cf4f3c":                                 "d014f9a8c9ee2589e13f0cc8b6630ca6",
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4": "fe4890d1e6188d0b046df344706c631e",
+	}
+	for k, last := range vectors {
+		key, _ := hex.DecodeString(k)
+		keys, err := ExpandKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != Rounds(len(key))+1 {
+			t.Errorf("expected %d round keys, got %d", Rounds(len(key))+1, len(keys))
+		}
+		if hex.EncodeToString(keys[0][:]) != k[:32] {
+			t.Errorf("expected the first round key to be the key, got %x", keys[0])
+		}
+		if actual := keys[len(keys)-1]; hex.EncodeToString(actual[:]) != last {
+			t.Errorf("expected last round key %s, got %x", last, actual)
+		}
+	}
+}
+
+func TestInvertKeySchedule(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+	keys, _ := ExpandKey(key)
+	for round, k := range keys {
+		recovered, err := InvertKeySchedule(k, round)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(recovered, key) {
+			t.Errorf("round %d: expected %x, got %x", round, key, recovered)
+		}
+	}
+
+	if _, err := InvertKeySchedule(keys[0], 11); err == nil {
+		t.Error("should fail given a round AES-128 doesn't have")
+	}
+}
+
+func TestInverses(t *testing.T) {
+	var s State
+	rand.Read(s[:])
+	original := s
+
+	ops := []struct {
+		name         string
+		forward, inv func(*State)
+	}{
+		{"SubBytes", SubBytes, InvSubBytes},
+		{"ShiftRows", ShiftRows, InvShiftRows},
+		{"MixColumns", MixColumns, InvMixColumns},
+	}
+	for _, op := range ops {
+		op.forward(&s)
+		if s == original {
+			t.Errorf("%s didn't change the state", op.name)
+		}
+		op.inv(&s)
+		if s != original {
+			t.Errorf("%s isn't undone by its inverse", op.name)
+		}
+	}
+
+	for i := 0; i < 256; i++ {
+		if InvSBox(SBox(byte(i))) != byte(i) {
+			t.Fatalf("S-box isn't inverted at %d", i)
+		}
+	}
+}
+
+func TestReducedRounds(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	plaintext := []byte("Ice Ice Baby....")
+
+	for rounds := 1; rounds <= 10; rounds++ {
+		c, err := NewCipherRounds(key, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext := make([]byte, BlockSize)
+		c.Encrypt(ciphertext, plaintext)
+		decrypted := make([]byte, BlockSize)
+		c.Decrypt(decrypted, ciphertext)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%d rounds: decryption failed", rounds)
+		}
+
+		// The last snapshot of a trace is the ciphertext
+		snapshots := c.Trace(plaintext)
+		last := snapshots[len(snapshots)-1]
+		if last.Round != rounds || !bytes.Equal(last.State[:], ciphertext) {
+			t.Errorf("%d rounds: trace ended at round %d with %x", rounds, last.Round, last.State)
+		}
+	}
+
+	if _, err := NewCipherRounds(key, 11); err == nil {
+		t.Error("should fail given more rounds than the key schedule provides")
+	}
+}
+
+func TestHookFault(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	plaintext := []byte("Ice Ice Baby....")
+	c, _ := NewCipher(key)
+	clean := make([]byte, BlockSize)
+	c.Encrypt(clean, plaintext)
+
+	// Flip a bit of the first byte before the last round's SubBytes. Only
+	// the one ciphertext byte it ends up in should change.
+	c.Hook = func(round int, step Step, s *State) {
+		if round == 9 && step == StepAddRoundKey {
+			s[0] ^= 1
+		}
+	}
+	faulty := make([]byte, BlockSize)
+	c.Encrypt(faulty, plaintext)
+
+	diff := 0
+	for i := range clean {
+		if clean[i] != faulty[i] {
+			diff++
+		}
+	}
+	if diff != 1 || clean[0] == faulty[0] {
+		t.Errorf("expected only byte 0 to differ, got %x and %x", clean, faulty)
+	}
+}
@@ -0,0 +1,85 @@
+// Package sha1 is a pure-Go SHA-1 whose internal state can be set, for
+// length extension attacks on secret-prefix MACs. Use crypto/sha1 for
+// anything else.
+package sha1
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+
+	"github.com/taravancil/cryptopals/mdhash"
+)
+
+// Size is the size of a SHA-1 digest in bytes
+const Size = 20
+
+// BlockSize is the block size of SHA-1 in bytes
+const BlockSize = mdhash.BlockSize
+
+var spec = &mdhash.Spec{
+	Initial: []uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0},
+	Order:   binary.BigEndian,
+	Block:   block,
+}
+
+// New returns a SHA-1 hash.Hash
+func New() hash.Hash {
+	return mdhash.New(spec)
+}
+
+// NewFromState returns a SHA-1 hash.Hash that carries on from the given
+// digest as if it had already hashed length bytes. length must include the
+// padding hashed before the digest was produced, so it is a multiple of
+// BlockSize. Reset returns to this state rather than the initial one.
+func NewFromState(sum []byte, length uint64) (hash.Hash, error) {
+	return mdhash.NewFromState(spec, sum, length)
+}
+
+// Sum returns the SHA-1 digest of data
+func Sum(data []byte) []byte {
+	h := New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Padding returns the padding SHA-1 appends to a message of messageLen
+// bytes: a 1 bit, zeros up to 8 bytes short of a block, then the message
+// length in bits, big-endian
+func Padding(messageLen uint64) []byte {
+	return spec.Padding(messageLen)
+}
+
+// block runs the compression function over one block
+func block(state []uint32, p []byte) {
+	var w [80]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[4*i:])
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+	}
+
+	a, b, c, dd, e := state[0], state[1], state[2], state[3], state[4]
+	for i := 0; i < 80; i++ {
+		var f, k uint32
+		switch {
+		case i < 20:
+			f, k = b&c|^b&dd, 0x5a827999
+		case i < 40:
+			f, k = b^c^dd, 0x6ed9eba1
+		case i < 60:
+			f, k = b&c|b&dd|c&dd, 0x8f1bbcdc
+		default:
+			f, k = b^c^dd, 0xca62c1d6
+		}
+		t := bits.RotateLeft32(a, 5) + f + e + k + w[i]
+		a, b, c, dd, e = t, a, bits.RotateLeft32(b, 30), c, dd
+	}
+
+	state[0] += a
+	state[1] += b
+	state[2] += c
+	state[3] += dd
+	state[4] += e
+}
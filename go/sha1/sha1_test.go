@@ -0,0 +1,82 @@
+package sha1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		data := make([]byte, n)
+		rand.Read(data)
+		expected := sha1.Sum(data)
+		if actual := Sum(data); !bytes.Equal(actual, expected[:]) {
+			t.Fatalf("length %d: expected %x, got %x", n, expected, actual)
+		}
+	}
+}
+
+func TestWritePieces(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.Read(data)
+	expected := sha1.Sum(data)
+
+	h := New()
+	for p := data; len(p) > 0; {
+		n := 1 + len(p)%97
+		if n > len(p) {
+			n = len(p)
+		}
+		h.Write(p[:n])
+		p = p[n:]
+
+		// Sum doesn't disturb the state
+		h.Sum(nil)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected[:]) {
+		t.Errorf("expected %x, got %x", expected, actual)
+	}
+
+	h.Reset()
+	h.Write(data)
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected[:]) {
+		t.Errorf("after Reset: expected %x, got %x", expected, actual)
+	}
+}
+
+func TestNewFromState(t *testing.T) {
+	message := []byte("comment1=cooking%20MCs;userdata=foo")
+	suffix := []byte(";admin=true")
+
+	// Hashing the padded message then the suffix continues the state left
+	// by the digest of the message
+	padded := append(append([]byte{}, message...), Padding(uint64(len(message)))...)
+	expected := sha1.Sum(append(append([]byte{}, padded...), suffix...))
+
+	h, err := NewFromState(Sum(message), uint64(len(padded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write(suffix)
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected[:]) {
+		t.Errorf("expected %x, got %x", expected, actual)
+	}
+
+	if _, err := NewFromState(make([]byte, Size), 10); err == nil {
+		t.Error("should fail given a length that isn't whole blocks")
+	}
+	if _, err := NewFromState(make([]byte, 16), 64); err == nil {
+		t.Error("should fail given a digest of the wrong size")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	for n := uint64(0); n < 200; n++ {
+		pad := Padding(n)
+		if (n+uint64(len(pad)))%BlockSize != 0 || pad[0] != 0x80 || len(pad) > BlockSize+8 {
+			t.Fatalf("length %d: bad padding %x", n, pad)
+		}
+	}
+}
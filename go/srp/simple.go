@@ -0,0 +1,154 @@
+package srp
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/taravancil/cryptopals/dh"
+)
+
+// Simplified SRP drops k*v from B and sends u instead of deriving it from
+// A and B. B no longer depends on the password, so a server that picks its
+// own b, u and salt can test password guesses against the client's proof
+// offline.
+
+type simpleChallenge struct {
+	Salt []byte
+	B    *big.Int
+	U    *big.Int
+}
+
+// SimpleServer is a simplified SRP server
+type SimpleServer struct {
+	registry
+}
+
+// NewSimpleServer returns a SimpleServer with no users for group
+func NewSimpleServer(group *dh.Group) *SimpleServer {
+	return &SimpleServer{newRegistry(group)}
+}
+
+// Serve runs one login over conn like Server.Serve
+func (s *SimpleServer) Serve(conn io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	g := s.group
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if missing(h.A) || zeroMod(h.A, g) {
+		return ErrLoginFailed
+	}
+	u, err := s.lookup(h.Email)
+	if err != nil {
+		return err
+	}
+
+	// B = g^b, and u is a random 128-bit number
+	b, err := dh.GenerateKey(g, nil)
+	if err != nil {
+		return err
+	}
+	scramble, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(&simpleChallenge{u.salt, b.Public, scramble}); err != nil {
+		return err
+	}
+
+	// S = (A * v^u)^b
+	S := new(big.Int).Exp(u.v, scramble, g.P)
+	S.Mul(S, h.A).Mod(S, g.P).Exp(S, b.X, g.P)
+
+	return checkProof(enc, dec, sessionKey(S), u.salt)
+}
+
+// SimpleClient is a simplified SRP client
+type SimpleClient struct {
+	Client
+}
+
+// NewSimpleClient returns a SimpleClient that logs in as email in group
+func NewSimpleClient(group *dh.Group, email, password string) *SimpleClient {
+	return &SimpleClient{Client{group, email, password}}
+}
+
+// Login runs one login over conn like Client.Login
+func (c *SimpleClient) Login(conn io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	g := c.group
+
+	a, err := dh.GenerateKey(g, nil)
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(&hello{c.Email, a.Public}); err != nil {
+		return err
+	}
+	var ch simpleChallenge
+	if err := receiveChallenge(dec, &ch); err != nil {
+		return err
+	}
+	if missing(ch.B) || missing(ch.U) {
+		return ErrLoginFailed
+	}
+	if zeroMod(ch.B, g) {
+		return errBZero
+	}
+
+	// S = B^(a + u * x)
+	x := privateKey(ch.Salt, c.Email, c.Password)
+	exp := new(big.Int).Mul(ch.U, x)
+	exp.Add(exp, a.X)
+	S := new(big.Int).Exp(ch.B, exp, g.P)
+
+	return sendProof(enc, dec, sessionKey(S), ch.Salt)
+}
+
+// CrackSimple poses as a simplified SRP server for one login over conn,
+// then tries each line of wordlist as the password offline. It sends
+// b = 1 and u = 1, so B = g and each guess costs one exponentiation:
+// S = A * g^x. The client is told its login succeeded.
+func CrackSimple(conn io.ReadWriter, group *dh.Group, wordlist io.Reader) (email, password string, err error) {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return "", "", err
+	}
+	if missing(h.A) {
+		return "", "", ErrLoginFailed
+	}
+	salt := []byte{}
+	if err := enc.Encode(&simpleChallenge{salt, group.G, big.NewInt(1)}); err != nil {
+		return "", "", err
+	}
+	var p proof
+	if err := dec.Decode(&p); err != nil {
+		return "", "", err
+	}
+	if err := enc.Encode(&verdict{true}); err != nil {
+		return "", "", err
+	}
+
+	words := bufio.NewScanner(wordlist)
+	for words.Scan() {
+		guess := words.Text()
+		S := new(big.Int).Exp(group.G, privateKey(salt, h.Email, guess), group.P)
+		S.Mul(S, h.A).Mod(S, group.P)
+		if hmac.Equal(p.MAC, mac(sessionKey(S), salt)) {
+			return h.Email, guess, nil
+		}
+	}
+	if err := words.Err(); err != nil {
+		return "", "", err
+	}
+	return h.Email, "", errors.New("srp: password not in the wordlist")
+}
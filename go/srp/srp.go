@@ -0,0 +1,301 @@
+// Package srp is SRP-6a over SHA-256, with clients and servers that talk
+// over an io.ReadWriter, a simplified variant, and attacks on both
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/taravancil/cryptopals/bytes"
+	"github.com/taravancil/cryptopals/dh"
+)
+
+// ErrLoginFailed is returned by both sides when the client's proof doesn't
+// check out
+var ErrLoginFailed = errors.New("srp: login failed")
+
+var errBZero = errors.New("srp: server sent B = 0 mod N")
+
+const saltLen = 16
+
+// The messages of a login, in order. gob carries them over the connection.
+type hello struct {
+	Email string
+	A     *big.Int
+}
+
+type challenge struct {
+	Salt []byte
+	B    *big.Int
+}
+
+type proof struct {
+	MAC []byte
+}
+
+type verdict struct {
+	OK bool
+}
+
+// registry holds each user's salt and verifier v = g^x mod N. It never
+// sees a password after Register.
+type registry struct {
+	group *dh.Group
+	users map[string]user
+}
+
+type user struct {
+	salt []byte
+	v    *big.Int
+}
+
+func newRegistry(group *dh.Group) registry {
+	return registry{group, make(map[string]user)}
+}
+
+// Register stores a salt and verifier for email's password
+func (r registry) Register(email, password string) error {
+	salt, err := bytes.Random(saltLen)
+	if err != nil {
+		return err
+	}
+	x := privateKey(salt, email, password)
+	r.users[email] = user{salt, new(big.Int).Exp(r.group.G, x, r.group.P)}
+	return nil
+}
+
+// lookup returns email's salt and verifier, or random ones for an unknown
+// user, so the server behaves the same either way and the login just fails
+func (r registry) lookup(email string) (user, error) {
+	if u, ok := r.users[email]; ok {
+		return u, nil
+	}
+	salt, err := bytes.Random(saltLen)
+	if err != nil {
+		return user{}, err
+	}
+	v, err := dh.GenerateKey(r.group, nil)
+	if err != nil {
+		return user{}, err
+	}
+	return user{salt, v.Public}, nil
+}
+
+// Server is an SRP-6a server
+type Server struct {
+	registry
+
+	// InsecureSkipCheckA makes Serve accept an A that's 0 mod N, which
+	// SRP-6a forbids. A client can then log in without the password by
+	// sending 0, N, 2N and so on.
+	InsecureSkipCheckA bool
+}
+
+// NewServer returns a Server with no users for group
+func NewServer(group *dh.Group) *Server {
+	return &Server{registry: newRegistry(group)}
+}
+
+// Serve runs one login over conn. It returns nil if the client proved it
+// knows the password and ErrLoginFailed if not. It stops without answering
+// an A it rejects, so the caller should close conn.
+func (s *Server) Serve(conn io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	g := s.group
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if missing(h.A) || !s.InsecureSkipCheckA && zeroMod(h.A, g) {
+		return ErrLoginFailed
+	}
+	u, err := s.lookup(h.Email)
+	if err != nil {
+		return err
+	}
+
+	// B = kv + g^b
+	b, err := dh.GenerateKey(g, nil)
+	if err != nil {
+		return err
+	}
+	B := new(big.Int).Mul(multiplier(g), u.v)
+	B.Add(B, b.Public).Mod(B, g.P)
+	if err := enc.Encode(&challenge{u.salt, B}); err != nil {
+		return err
+	}
+
+	// S = (A * v^u)^b
+	scramble := scrambler(g, h.A, B)
+	S := new(big.Int).Exp(u.v, scramble, g.P)
+	S.Mul(S, h.A).Mod(S, g.P).Exp(S, b.X, g.P)
+
+	return checkProof(enc, dec, sessionKey(S), u.salt)
+}
+
+// Client is an SRP-6a client
+type Client struct {
+	group           *dh.Group
+	Email, Password string
+}
+
+// NewClient returns a Client that logs in as email in group
+func NewClient(group *dh.Group, email, password string) *Client {
+	return &Client{group, email, password}
+}
+
+// Login runs one login over conn. It returns nil if the server accepted
+// the password and ErrLoginFailed if not.
+func (c *Client) Login(conn io.ReadWriter) error {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	g := c.group
+
+	a, err := dh.GenerateKey(g, nil)
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(&hello{c.Email, a.Public}); err != nil {
+		return err
+	}
+	var ch challenge
+	if err := receiveChallenge(dec, &ch); err != nil {
+		return err
+	}
+	if missing(ch.B) {
+		return ErrLoginFailed
+	}
+	if zeroMod(ch.B, g) {
+		return errBZero
+	}
+
+	// S = (B - k * g^x)^(a + u * x)
+	x := privateKey(ch.Salt, c.Email, c.Password)
+	base := new(big.Int).Exp(g.G, x, g.P)
+	base.Mul(base, multiplier(g)).Sub(ch.B, base).Mod(base, g.P)
+	exp := new(big.Int).Mul(scrambler(g, a.Public, ch.B), x)
+	exp.Add(exp, a.X)
+	S := new(big.Int).Exp(base, exp, g.P)
+
+	return sendProof(enc, dec, sessionKey(S), ch.Salt)
+}
+
+// LoginWithoutPassword logs in as email by sending A = multiple * N. The
+// server's secret (A * v^u)^b is then 0 mod N whatever the password, so the
+// client proves it knows the session key H(0).
+func LoginWithoutPassword(conn io.ReadWriter, group *dh.Group, email string, multiple int64) error {
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+	A := new(big.Int).Mul(big.NewInt(multiple), group.P)
+	if err := enc.Encode(&hello{email, A}); err != nil {
+		return err
+	}
+	var ch challenge
+	if err := receiveChallenge(dec, &ch); err != nil {
+		return err
+	}
+	return sendProof(enc, dec, sessionKey(big.NewInt(0)), ch.Salt)
+}
+
+// receiveChallenge reads the server's challenge into ch. A server that
+// hangs up instead has rejected the login.
+func receiveChallenge(dec *gob.Decoder, ch interface{}) error {
+	err := dec.Decode(ch)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrLoginFailed
+	}
+	return err
+}
+
+// sendProof sends HMAC(K, salt) and reads back whether the server took it
+func sendProof(enc *gob.Encoder, dec *gob.Decoder, key, salt []byte) error {
+	if err := enc.Encode(&proof{mac(key, salt)}); err != nil {
+		return err
+	}
+	var v verdict
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if !v.OK {
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// checkProof reads the client's proof, checks it against HMAC(K, salt) and
+// tells the client whether it passed
+func checkProof(enc *gob.Encoder, dec *gob.Decoder, key, salt []byte) error {
+	var p proof
+	if err := dec.Decode(&p); err != nil {
+		return err
+	}
+	ok := hmac.Equal(p.MAC, mac(key, salt))
+	if err := enc.Encode(&verdict{ok}); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// missing reports whether a number from the other side is absent or
+// negative. gob leaves a pointer nil when the sender didn't set it.
+func missing(n *big.Int) bool {
+	return n == nil || n.Sign() < 0
+}
+
+// zeroMod reports whether n is 0 mod N
+func zeroMod(n *big.Int, g *dh.Group) bool {
+	return new(big.Int).Mod(n, g.P).Sign() == 0
+}
+
+// privateKey returns x = H(salt | H(email ":" password)), as in RFC 5054
+func privateKey(salt []byte, email, password string) *big.Int {
+	inner := sha256.Sum256([]byte(email + ":" + password))
+	return hashInt(salt, inner[:])
+}
+
+// multiplier returns k = H(N | PAD(g))
+func multiplier(g *dh.Group) *big.Int {
+	return hashInt(g.P.Bytes(), pad(g, g.G))
+}
+
+// scrambler returns u = H(PAD(A) | PAD(B))
+func scrambler(g *dh.Group, A, B *big.Int) *big.Int {
+	return hashInt(pad(g, A), pad(g, B))
+}
+
+// sessionKey returns K = H(S)
+func sessionKey(S *big.Int) []byte {
+	sum := sha256.Sum256(S.Bytes())
+	return sum[:]
+}
+
+func mac(key, salt []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(salt)
+	return h.Sum(nil)
+}
+
+// pad returns n's big-endian bytes left-padded to the length of N
+func pad(g *dh.Group, n *big.Int) []byte {
+	b := n.Bytes()
+	size := (g.P.BitLen() + 7) / 8
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func hashInt(parts ...[]byte) *big.Int {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
@@ -0,0 +1,182 @@
+package srp
+
+import (
+	"encoding/gob"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/taravancil/cryptopals/dh"
+)
+
+const (
+	email    = "foo@bar.com"
+	password = "hunter2"
+)
+
+// run connects serve and login with a net.Pipe and returns what each side
+// returned
+func run(serve, login func(io.ReadWriter) error) (serverErr, clientErr error) {
+	s, c := net.Pipe()
+	done := make(chan error)
+	go func() {
+		err := serve(s)
+		s.Close()
+		done <- err
+	}()
+	clientErr = login(c)
+	c.Close()
+	return <-done, clientErr
+}
+
+func TestLogin(t *testing.T) {
+	server := NewServer(dh.MODP1536)
+	if err := server.Register(email, password); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		email, password string
+		err             error
+	}{
+		{email, password, nil},
+		{email, "hunter3", ErrLoginFailed},
+		{"baz@bar.com", password, ErrLoginFailed},
+	} {
+		client := NewClient(dh.MODP1536, c.email, c.password)
+		serverErr, clientErr := run(server.Serve, client.Login)
+		if serverErr != c.err || clientErr != c.err {
+			t.Errorf("%s/%s: expected %v, got server %v, client %v", c.email, c.password, c.err, serverErr, clientErr)
+		}
+	}
+}
+
+func TestLoginWithoutPassword(t *testing.T) {
+	server := NewServer(dh.MODP1536)
+	if err := server.Register(email, password); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, multiple := range []int64{0, 1, 2} {
+		login := func(conn io.ReadWriter) error {
+			return LoginWithoutPassword(conn, dh.MODP1536, email, multiple)
+		}
+
+		server.InsecureSkipCheckA = true
+		serverErr, clientErr := run(server.Serve, login)
+		if serverErr != nil || clientErr != nil {
+			t.Errorf("A = %dN: expected to log in, got server %v, client %v", multiple, serverErr, clientErr)
+		}
+
+		server.InsecureSkipCheckA = false
+		serverErr, clientErr = run(server.Serve, login)
+		if serverErr != ErrLoginFailed || clientErr != ErrLoginFailed {
+			t.Errorf("A = %dN: expected the check to stop the login, got server %v, client %v", multiple, serverErr, clientErr)
+		}
+	}
+}
+
+func TestSimpleLogin(t *testing.T) {
+	server := NewSimpleServer(dh.MODP1536)
+	if err := server.Register(email, password); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		password string
+		err      error
+	}{
+		{password, nil},
+		{"hunter3", ErrLoginFailed},
+	} {
+		client := NewSimpleClient(dh.MODP1536, email, c.password)
+		serverErr, clientErr := run(server.Serve, client.Login)
+		if serverErr != c.err || clientErr != c.err {
+			t.Errorf("%s: expected %v, got server %v, client %v", c.password, c.err, serverErr, clientErr)
+		}
+	}
+}
+
+func TestCrackSimple(t *testing.T) {
+	wordlist := "password\nletmein\nhunter2\ntrustno1\n"
+	client := NewSimpleClient(dh.MODP1536, email, password)
+
+	var gotEmail, gotPassword string
+	crack := func(conn io.ReadWriter) error {
+		var err error
+		gotEmail, gotPassword, err = CrackSimple(conn, dh.MODP1536, strings.NewReader(wordlist))
+		return err
+	}
+	serverErr, clientErr := run(crack, client.Login)
+	if serverErr != nil || clientErr != nil {
+		t.Fatalf("expected the client to log in and the crack to work, got server %v, client %v", serverErr, clientErr)
+	}
+	if gotEmail != email || gotPassword != password {
+		t.Errorf("expected %s/%s, got %s/%s", email, password, gotEmail, gotPassword)
+	}
+
+	// A password that isn't in the wordlist
+	client.Password = "correct horse battery staple"
+	serverErr, _ = run(crack, client.Login)
+	if serverErr == nil {
+		t.Error("expected an error for a password not in the wordlist")
+	}
+}
+
+func TestMissingNumbers(t *testing.T) {
+	server := NewServer(dh.MODP1536)
+	if err := server.Register(email, password); err != nil {
+		t.Fatal(err)
+	}
+	simple := NewSimpleServer(dh.MODP1536)
+	if err := simple.Register(email, password); err != nil {
+		t.Fatal(err)
+	}
+	crack := func(conn io.ReadWriter) error {
+		_, _, err := CrackSimple(conn, dh.MODP1536, strings.NewReader(password))
+		return err
+	}
+
+	// Servers given a nil or negative A
+	for _, A := range []*big.Int{nil, big.NewInt(-1)} {
+		sendHello := func(conn io.ReadWriter) error {
+			return gob.NewEncoder(conn).Encode(&hello{email, A})
+		}
+		for name, serve := range map[string]func(io.ReadWriter) error{
+			"Server":       server.Serve,
+			"SimpleServer": simple.Serve,
+			"CrackSimple":  crack,
+		} {
+			if serverErr, _ := run(serve, sendHello); serverErr != ErrLoginFailed {
+				t.Errorf("%s, A = %v: expected %v, got %v", name, A, ErrLoginFailed, serverErr)
+			}
+		}
+	}
+
+	// Clients given a nil or negative B or u
+	answer := func(ch interface{}) func(io.ReadWriter) error {
+		return func(conn io.ReadWriter) error {
+			var h hello
+			if err := gob.NewDecoder(conn).Decode(&h); err != nil {
+				return err
+			}
+			return gob.NewEncoder(conn).Encode(ch)
+		}
+	}
+	salt := []byte("salt")
+	two := big.NewInt(2)
+	client := NewClient(dh.MODP1536, email, password)
+	for _, ch := range []*challenge{{salt, nil}, {salt, big.NewInt(-2)}} {
+		if _, clientErr := run(answer(ch), client.Login); clientErr != ErrLoginFailed {
+			t.Errorf("Client, B = %v: expected %v, got %v", ch.B, ErrLoginFailed, clientErr)
+		}
+	}
+	simpleClient := NewSimpleClient(dh.MODP1536, email, password)
+	for _, ch := range []*simpleChallenge{{salt, nil, two}, {salt, two, nil}, {salt, two, big.NewInt(-2)}} {
+		if _, clientErr := run(answer(ch), simpleClient.Login); clientErr != ErrLoginFailed {
+			t.Errorf("SimpleClient, B = %v, u = %v: expected %v, got %v", ch.B, ch.U, ErrLoginFailed, clientErr)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package timing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/taravancil/cryptopals/sha1"
+)
+
+const (
+	// How many requests are in flight while finding the first byte, before
+	// the delay is known
+	initialWorkers = 8
+	maxWorkers     = 256
+
+	// How many requests time the server's overhead once the first byte is
+	// known
+	calibrationRequests = 64
+
+	// How many of the slowest guesses must be timed again before one can
+	// stand out, and how many timings each may get
+	contenders = 8
+	maxSamples = 32
+
+	// How far ahead of the runner-up the first byte must be, in median
+	// absolute deviations of all the guesses' timings, before the delay is
+	// known
+	clearMargin = 6
+
+	maxBacktracks = 8
+)
+
+type attacker struct {
+	client  *http.Client
+	base    string
+	file    string
+	workers int
+
+	// The server's delay, estimated from how far the first byte stood out
+	delay time.Duration
+}
+
+// RecoverSignature recovers the HMAC-SHA1 signature a Server at baseURL
+// expects for file, a byte at a time. A guess whose first i+1 bytes are
+// right takes one more delay to reject than the guesses that are wrong at
+// byte i, so the slowest guess gives the next byte. Timings are noisy, so
+// each is measured against guesses sent around the same time, the slowest
+// guesses are timed several times and compared by their medians, and the
+// attack backs up a byte when no guess stands out.
+//
+// Guesses are sent concurrently, since every guess with the right prefix
+// waits out the delays for it. Requests queueing for the CPU add noise, so
+// the concurrency is chosen to keep that noise well under the delay the
+// first byte reveals.
+func RecoverSignature(baseURL, file string) ([]byte, error) {
+	a := &attacker{
+		client: &http.Client{
+			Transport: &http.Transport{MaxIdleConnsPerHost: maxWorkers},
+			Timeout:   time.Minute,
+		},
+		base:    baseURL,
+		file:    file,
+		workers: initialWorkers,
+	}
+
+	guess := make([]byte, sha1.Size)
+	backtracks := 0
+	for i := 0; i < len(guess); {
+		var b byte
+		var ok bool
+		var err error
+
+		// The last byte doesn't need timing, just a valid response
+		if i == len(guess)-1 {
+			b, ok, err = a.lastByte(guess)
+		} else {
+			b, ok, err = a.nextByte(guess, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			guess[i] = b
+			if i == len(guess)-1 {
+				return guess, nil
+			}
+			if i == 0 {
+				if err := a.calibrate(guess); err != nil {
+					return nil, err
+				}
+			}
+			i++
+			continue
+		}
+
+		// An earlier byte must be wrong
+		backtracks++
+		if backtracks > maxBacktracks || i == 0 {
+			return nil, fmt.Errorf("no guess stands out at byte %d", i)
+		}
+		i--
+	}
+	return guess, nil
+}
+
+// query sends a guess and returns how long the server took to answer and
+// the response's status code
+func (a *attacker) query(guess []byte) (time.Duration, int, error) {
+	u := a.base + "/?file=" + url.QueryEscape(a.file) + "&signature=" + hex.EncodeToString(guess)
+	start := time.Now()
+	resp, err := a.client.Get(u)
+	if err != nil {
+		return 0, 0, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return time.Since(start), resp.StatusCode, nil
+}
+
+// calibrate sets the concurrency from the delay the first byte revealed
+// and how long requests that don't reach a delay take to serve
+func (a *attacker) calibrate(guess []byte) error {
+	wrong := append([]byte{}, guess...)
+	wrong[0] ^= 1
+	candidates := make([]byte, calibrationRequests)
+	for i := range candidates {
+		candidates[i] = wrong[0]
+	}
+
+	start := time.Now()
+	if _, err := a.queryAll(wrong, 0, candidates); err != nil {
+		return err
+	}
+	perRequest := time.Since(start) / calibrationRequests
+
+	// With n requests queued, one may wait about n requests' worth of time
+	// for the CPU
+	a.workers = int(a.delay / (4 * perRequest))
+	if a.workers < 1 {
+		a.workers = 1
+	}
+	if a.workers > maxWorkers {
+		a.workers = maxWorkers
+	}
+	return nil
+}
+
+// timing is one timed guess
+type timing struct {
+	candidate byte
+	start     time.Time
+	took      time.Duration
+	status    int
+}
+
+// queryAll sends the guesses that differ from guess only in byte i, once
+// per candidate, and returns their timings in the order they were sent
+func (a *attacker) queryAll(guess []byte, i int, candidates []byte) ([]timing, error) {
+	jobs := make(chan int)
+	timings := make([]timing, len(candidates))
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for w := 0; w < a.workers && w < len(candidates); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g := make([]byte, len(guess))
+			copy(g, guess)
+			for j := range jobs {
+				g[i] = candidates[j]
+				start := time.Now()
+				took, status, err := a.query(g)
+				if err != nil {
+					once.Do(func() { firstErr = err })
+					continue
+				}
+				timings[j] = timing{candidates[j], start, took, status}
+			}
+		}()
+	}
+	for j := range candidates {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	return timings, firstErr
+}
+
+// residuals returns how much slower each guess was than the guesses sent
+// around the same time. The server slows down and speeds up as requests
+// bunch up, so only guesses timed together are comparable.
+func (a *attacker) residuals(timings []timing) map[byte]time.Duration {
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].start.Before(timings[j].start)
+	})
+
+	window := 2 * a.workers
+	if window < 8 {
+		window = 8
+	}
+	residuals := make(map[byte]time.Duration, len(timings))
+	for i, t := range timings {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(timings) {
+			hi = len(timings)
+		}
+		neighbors := make([]time.Duration, 0, hi-lo)
+		for _, n := range timings[lo:hi] {
+			neighbors = append(neighbors, n.took)
+		}
+		residuals[t.candidate] = t.took - median(neighbors)
+	}
+	return residuals
+}
+
+// nextByte times every value of byte i and returns the one that stands out
+// as slowest, or false if none does
+func (a *attacker) nextByte(guess []byte, i int) (byte, bool, error) {
+	samples := make(map[byte][]time.Duration)
+	sample := func(candidates []byte) error {
+		shuffled := append([]byte{}, candidates...)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		timings, err := a.queryAll(guess, i, shuffled)
+		if err != nil {
+			return err
+		}
+		for c, r := range a.residuals(timings) {
+			samples[c] = append(samples[c], r)
+		}
+		return nil
+	}
+
+	all := make([]byte, 256)
+	for c := range all {
+		all[c] = byte(c)
+	}
+	if err := sample(all); err != nil {
+		return 0, false, err
+	}
+
+	for n := 2; n <= maxSamples; n *= 2 {
+		// Give the slowest guesses more timings, so an outlier can't win
+		// on one lucky sample
+		for {
+			ranked := rankByMedian(samples)
+			if fewest(samples, ranked[:contenders]) >= n {
+				break
+			}
+
+			// A pass takes as long as its slowest request, so time as many
+			// of the slowest guesses as there are workers
+			batch := contenders
+			if a.workers > batch {
+				batch = a.workers
+			}
+			if batch > len(ranked) {
+				batch = len(ranked)
+			}
+			if err := sample(ranked[:batch]); err != nil {
+				return 0, false, err
+			}
+		}
+
+		ranked := rankByMedian(samples)
+		best, second := median(samples[ranked[0]]), median(samples[ranked[1]])
+		if a.delay == 0 {
+			if best-second > clearMargin*spread(samples) {
+				a.delay = best
+				return ranked[0], true, nil
+			}
+			continue
+		}
+
+		// Once the delay is known, the right guess is the only one more
+		// than half a delay slower than its neighbors
+		if best > a.delay/2 && second < a.delay/2 {
+			return ranked[0], true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// lastByte tries every value of the last byte and returns the one the
+// server accepts
+func (a *attacker) lastByte(guess []byte) (byte, bool, error) {
+	all := make([]byte, 256)
+	for c := range all {
+		all[c] = byte(c)
+	}
+	timings, err := a.queryAll(guess, len(guess)-1, all)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var found []byte
+	for _, t := range timings {
+		if t.status == http.StatusOK {
+			found = append(found, t.candidate)
+		}
+	}
+	if len(found) != 1 {
+		return 0, false, nil
+	}
+	return found[0], true, nil
+}
+
+// rankByMedian returns the candidates from slowest to fastest median
+func rankByMedian(samples map[byte][]time.Duration) []byte {
+	ranked := make([]byte, 0, len(samples))
+	medians := make(map[byte]time.Duration, len(samples))
+	for c, s := range samples {
+		ranked = append(ranked, c)
+		medians[c] = median(s)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return medians[ranked[i]] > medians[ranked[j]]
+	})
+	return ranked
+}
+
+// fewest returns the fewest timings any of candidates has
+func fewest(samples map[byte][]time.Duration, candidates []byte) int {
+	min := len(samples[candidates[0]])
+	for _, c := range candidates[1:] {
+		if len(samples[c]) < min {
+			min = len(samples[c])
+		}
+	}
+	return min
+}
+
+// median returns the lower median, so a guess timed twice only ranks as
+// slow if both timings are
+func median(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := append([]time.Duration{}, d...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[(len(s)-1)/2]
+}
+
+// spread returns the median absolute deviation of the candidates' median
+// timings, a measure of the noise that isn't thrown off by the one slow
+// candidate
+func spread(samples map[byte][]time.Duration) time.Duration {
+	medians := make([]time.Duration, 0, len(samples))
+	for _, s := range samples {
+		medians = append(medians, median(s))
+	}
+	mid := median(medians)
+
+	deviations := make([]time.Duration, len(medians))
+	for i, m := range medians {
+		if m > mid {
+			deviations[i] = m - mid
+		} else {
+			deviations[i] = mid - m
+		}
+	}
+	return median(deviations)
+}
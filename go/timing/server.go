@@ -0,0 +1,76 @@
+// Package timing is an HTTP server that leaks how much of a signature is
+// right through how long it takes to reject it, and a client that recovers
+// the signature from those timings
+package timing
+
+import (
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/taravancil/cryptopals/crypto"
+	"github.com/taravancil/cryptopals/sha1"
+)
+
+// Server checks ?file=...&signature=... requests, where signature is the
+// hex HMAC-SHA1 of file. It answers 200 for a valid signature and 500
+// otherwise, and compares signatures with InsecureCompare.
+type Server struct {
+	mac   *crypto.HMAC
+	delay time.Duration
+}
+
+// NewServer returns a Server keyed with key whose comparison sleeps for
+// delay after each matching byte
+func NewServer(key []byte, delay time.Duration) *Server {
+	return &Server{crypto.NewHMAC(sha1.New, key), delay}
+}
+
+// Sign returns the signature the server expects for file
+func (s *Server) Sign(file string) []byte {
+	return s.mac.Sign([]byte(file))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	signature, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	if err != nil {
+		http.Error(w, "invalid signature encoding", http.StatusBadRequest)
+		return
+	}
+
+	if !InsecureCompare(s.Sign(file), signature, s.delay) {
+		http.Error(w, "invalid signature", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// InsecureCompare compares two byte slices a byte at a time, sleeping for
+// delay after each byte that matches and returning at the first that
+// doesn't. The time it takes gives away the length of the matching prefix.
+func InsecureCompare(a, b []byte, delay time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+		time.Sleep(delay)
+	}
+	return true
+}
+
+// ListenLoopback serves s on a free port on 127.0.0.1 until close is
+// called and returns the server's base URL
+func (s *Server) ListenLoopback() (url string, close func() error, err error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: s}
+	go srv.Serve(l)
+	return "http://" + l.Addr().String(), srv.Close, nil
+}
@@ -0,0 +1,99 @@
+package timing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInsecureCompare(t *testing.T) {
+	if !InsecureCompare([]byte("abc"), []byte("abc"), 0) {
+		t.Error("expected equal slices to match")
+	}
+	if InsecureCompare([]byte("abc"), []byte("abd"), 0) || InsecureCompare([]byte("abc"), []byte("ab"), 0) {
+		t.Error("expected different slices not to match")
+	}
+
+	// Two matching bytes take at least two delays. A loaded machine can
+	// take longer, so there's no upper bound.
+	start := time.Now()
+	InsecureCompare([]byte("abc"), []byte("abd"), 20*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected at least 40ms, took %v", elapsed)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer([]byte("YELLOW SUBMARINE"), 0)
+	base, stop, err := s.ListenLoopback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	signature := hex.EncodeToString(s.Sign("foo"))
+	for query, status := range map[string]int{
+		"file=foo&signature=" + signature:      http.StatusOK,
+		"file=bar&signature=" + signature:      http.StatusInternalServerError,
+		"file=foo&signature=" + signature[:38]: http.StatusInternalServerError,
+		"file=foo&signature=xyz":               http.StatusBadRequest,
+	} {
+		resp, err := http.Get(base + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: expected %d, got %d", query, status, resp.StatusCode)
+		}
+	}
+}
+
+func testRecoverSignature(t *testing.T, delay time.Duration) {
+	if testing.Short() {
+		t.Skip("sends thousands of timed requests, which can take minutes")
+	}
+
+	s := NewServer([]byte("YELLOW SUBMARINE"), delay)
+	base, stop, err := s.ListenLoopback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	file := "/etc/passwd & more"
+	start := time.Now()
+	recovered, err := RecoverSignature(base, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(recovered) != hex.EncodeToString(s.Sign(file)) {
+		t.Errorf("expected %x, got %x", s.Sign(file), recovered)
+	}
+	t.Logf("recovered %x in %v", recovered, time.Since(start))
+
+	resp, err := http.Get(base + "/?file=" + url.QueryEscape(file) + "&signature=" + hex.EncodeToString(recovered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the server to accept the signature, got %d", resp.StatusCode)
+	}
+}
+
+func TestRecoverSignature5ms(t *testing.T) {
+	testRecoverSignature(t, 5*time.Millisecond)
+}
+
+// The 50ms leak takes ten times as long to measure, so it only runs when
+// asked for
+func TestRecoverSignature50ms(t *testing.T) {
+	if os.Getenv("CRYPTOPALS_SLOW_TESTS") == "" {
+		t.Skip("set CRYPTOPALS_SLOW_TESTS=1 to run; it can take minutes")
+	}
+	testRecoverSignature(t, 50*time.Millisecond)
+}
@@ -92,6 +92,57 @@ func EnglishScore(s string) float64 {
 	return score
 }
 
+// englishByteLogProbs and englishInitialLogProbs hold the natural log of the
+// probability of each byte value appearing in English text, anywhere and at
+// the start of a line respectively
+var englishByteLogProbs = englishLogProbs(.72, .05)
+var englishInitialLogProbs = englishLogProbs(.05, .72)
+
+// englishLogProbs builds a table of byte log-probabilities, with letters
+// split between lowercase and uppercase by the given weights
+func englishLogProbs(lower, upper float64) [256]float64 {
+	var probs [256]float64
+
+	// Anything unexpected, including control characters and bytes outside
+	// of ASCII, is very unlikely but not impossible
+	for i := range probs {
+		probs[i] = 1e-6
+	}
+	for c := byte(33); c < 127; c++ {
+		probs[c] = 1e-3
+	}
+	for c := byte('0'); c <= '9'; c++ {
+		probs[c] = 5e-4
+	}
+	for _, c := range []byte(".,'-!?;:\"") {
+		probs[c] = 5e-3
+	}
+	probs[' '] = .15
+	probs['\n'] = 1e-3
+
+	for letter, freq := range ENGLISH_FREQUENCIES {
+		probs[letter[0]] = freq * upper
+		probs[strings.ToLower(letter)[0]] = freq * lower
+	}
+
+	for i, p := range probs {
+		probs[i] = math.Log(p)
+	}
+	return probs
+}
+
+// EnglishByteScore returns the log-likelihood of a byte appearing in English
+// text. Scores of several bytes can be summed to score them together.
+func EnglishByteScore(b byte) float64 {
+	return englishByteLogProbs[b]
+}
+
+// EnglishInitialByteScore is like EnglishByteScore for the first byte of a
+// line, where capital letters are more likely than lowercase ones
+func EnglishInitialByteScore(b byte) float64 {
+	return englishInitialLogProbs[b]
+}
+
 // ReadAndStripFile reads a file with ioutil and returns a version of
 // the bytes read from the file with all ASCII control characters
 // stripped.
@@ -32,3 +32,21 @@ func TestStrip(t *testing.T) {
 		t.Errorf("expected %s, got %s", string(ok), string(stripped))
 	}
 }
+
+func TestEnglishByteScore(t *testing.T) {
+	if EnglishByteScore('e') < EnglishByteScore('E') {
+		t.Error("lowercase letters should score higher than uppercase")
+	}
+	if EnglishByteScore(' ') < EnglishByteScore('z') {
+		t.Error("a space should score higher than a rare letter")
+	}
+	if EnglishByteScore('q') < EnglishByteScore(0x01) {
+		t.Error("a letter should score higher than a control character")
+	}
+}
+
+func TestEnglishInitialByteScore(t *testing.T) {
+	if EnglishInitialByteScore('T') < EnglishInitialByteScore('t') {
+		t.Error("capital letters should score higher at the start of a line")
+	}
+}